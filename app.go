@@ -2,10 +2,23 @@ package privatutle
 
 import (
 	"context"
+	"log"
+	"net"
 	"os"
+	"privaTutle/internal/analytics"
+	"privaTutle/internal/blob"
+	"privaTutle/internal/claim"
+	"privaTutle/internal/codegen"
+	"privaTutle/internal/customdomain"
+	"privaTutle/internal/health"
+	"privaTutle/internal/link"
+	"privaTutle/internal/mediastore"
+	"privaTutle/internal/policy"
+	"privaTutle/internal/sweeper"
+	"privaTutle/internal/trash"
+	"privaTutle/internal/upload"
 	"privaTutle/router"
 
-	"privaTutle/service/media"
 	"privaTutle/service/short"
 	"privaTutle/service/user"
 
@@ -27,6 +40,32 @@ func configInit() {
 	cnf.SetConfigName("app")
 	cnf.SetConfigType("yaml")
 	cnf.AutomaticEnv()
+	cnf.SetDefault("short.redirectStatus", 302)
+	cnf.SetDefault("short.generator.strategy", codegen.StrategyHash)
+	cnf.SetDefault("short.generator.length", 7)
+	cnf.SetDefault("short.generator.alphabet", codegen.DefaultAlphabet)
+	cnf.SetDefault("short.canonical.stripTracking", false)
+	cnf.SetDefault("urlPolicy.schemes", []string{"http", "https"})
+	cnf.SetDefault("urlPolicy.blockPrivate", true)
+	cnf.SetDefault("urlPolicy.reloadInterval", "30s")
+	cnf.SetDefault("health.interval", "10m")
+	cnf.SetDefault("health.recheckAfter", "24h")
+	cnf.SetDefault("health.batch", 200)
+	cnf.SetDefault("health.concurrency", 8)
+	cnf.SetDefault("health.hostInterval", "2s")
+	cnf.SetDefault("health.timeout", "10s")
+	cnf.SetDefault("trash.retention", "720h")
+	cnf.SetDefault("trash.interval", "1h")
+	cnf.SetDefault("trash.batch", 100)
+	cnf.SetDefault("claim.ttl", "720h")
+	cnf.SetDefault("media.maxSize", 2<<30)
+	cnf.SetDefault("media.urlTTL", "15m")
+	cnf.SetDefault("upload.ttl", "24h")
+	cnf.SetDefault("upload.interval", "1h")
+	cnf.SetDefault("sweeper.interval", "10m")
+	cnf.SetDefault("sweeper.batch", 100)
+	cnf.SetDefault("storage.backend", blob.BackendGCS)
+	cnf.SetDefault("storage.local.dir", "./data")
 
 	err := cnf.ReadInConfig()
 	if err != nil {
@@ -57,6 +96,50 @@ func gcsConn() *storage.Client {
 	return gcsClient
 }
 
+// blobConn opens the store all media content is kept in. Google credentials
+// are only needed for the gcs backend and the legacy bucket.
+func blobConn() blob.Store {
+	switch backend := cnf.GetString("storage.backend"); backend {
+	case blob.BackendGCS:
+		return blob.NewGCSStore(gcsConn(), cnf.GetString("storage.gcs.bucket"))
+	case blob.BackendLocal:
+		store, err := blob.NewLocalStore(cnf.GetString("storage.local.dir"))
+		if err != nil {
+			panic(err)
+		}
+		return store
+	case blob.BackendS3:
+		store, err := blob.NewS3Store(blob.S3Config{
+			Endpoint:  cnf.GetString("storage.s3.endpoint"),
+			Region:    cnf.GetString("storage.s3.region"),
+			Bucket:    cnf.GetString("storage.s3.bucket"),
+			AccessKey: cnf.GetString("storage.s3.accessKey"),
+			SecretKey: cnf.GetString("storage.s3.secretKey"),
+			PathStyle: cnf.GetBool("storage.s3.pathStyle"),
+		})
+		if err != nil {
+			panic(err)
+		}
+		return store
+	default:
+		panic("unknown storage.backend " + backend)
+	}
+}
+
+// legacyConn opens the bucket the former media service kept its content in,
+// or returns nil when none is configured.
+func legacyConn(blobStore blob.Store) blob.Store {
+	bucket := cnf.GetString("storage.legacy.bucket")
+	if bucket == "" {
+		return nil
+	}
+	if cnf.GetString("storage.backend") == blob.BackendGCS && bucket == cnf.GetString("storage.gcs.bucket") {
+		return blobStore
+	}
+
+	return blob.NewGCSStore(gcsConn(), bucket)
+}
+
 func lineBotConn() *linebot.Client {
 	bot, err := linebot.New(cnf.GetString("line.secret"), cnf.GetString("line.accessToken"))
 	if err != nil {
@@ -66,18 +149,63 @@ func lineBotConn() *linebot.Client {
 	return bot
 }
 
-func serviceBuild(database *mongo.Database, gcsClient *storage.Client) {
+func serviceBuild(database *mongo.Database, blobStore, legacyStore blob.Store) {
 	user.NewUserService(database)
 	short.NewShortService(database)
-	media.NewMediaService(database, gcsClient)
+	mediastore.NewMediaStoreService(database, blobStore, legacyStore, mediastore.Config{
+		MaxSize:    cnf.GetInt64("media.maxSize"),
+		URLTTL:     cnf.GetDuration("media.urlTTL"),
+		SigningKey: cnf.GetString("media.signingKey"),
+		BaseURL:    cnf.GetString("api.host"),
+	})
+	upload.NewUploadService(database, upload.Config{
+		TTL:      cnf.GetDuration("upload.ttl"),
+		Interval: cnf.GetDuration("upload.interval"),
+	})
+	sweeper.NewSweeperService(sweeper.Config{
+		Interval: cnf.GetDuration("sweeper.interval"),
+		Batch:    cnf.GetInt("sweeper.batch"),
+	})
+	link.NewLinkService(database, cnf.GetBool("short.canonical.stripTracking"))
+	analytics.NewAnalyticsService(database, cnf.GetString("analytics.salt"))
+	codegen.NewCodeService(database, codegen.Config{
+		Strategy: cnf.GetString("short.generator.strategy"),
+		Length:   cnf.GetInt("short.generator.length"),
+		Alphabet: cnf.GetString("short.generator.alphabet"),
+		Seed:     cnf.GetInt64("short.generator.seed"),
+	})
+	customdomain.NewDomainService(database, net.DefaultResolver, []string{cnf.GetString("frontend.host"), cnf.GetString("api.host")})
+	policy.NewPolicyService(policy.Config{
+		Schemes:        cnf.GetStringSlice("urlPolicy.schemes"),
+		BlockPrivate:   cnf.GetBool("urlPolicy.blockPrivate"),
+		SelfHosts:      []string{cnf.GetString("frontend.host"), cnf.GetString("api.host")},
+		IsSelfHost:     customdomain.DomainService.IsCustomDomain,
+		ListFile:       cnf.GetString("urlPolicy.listFile"),
+		ReloadInterval: cnf.GetDuration("urlPolicy.reloadInterval"),
+	})
+	health.NewHealthService(health.Config{
+		Interval:     cnf.GetDuration("health.interval"),
+		RecheckAfter: cnf.GetDuration("health.recheckAfter"),
+		Batch:        cnf.GetInt64("health.batch"),
+		Concurrency:  cnf.GetInt("health.concurrency"),
+		HostInterval: cnf.GetDuration("health.hostInterval"),
+		Timeout:      cnf.GetDuration("health.timeout"),
+	})
+	claim.NewClaimService(database, cnf.GetDuration("claim.ttl"))
+	trash.NewTrashService(database, trash.Config{
+		Retention: cnf.GetDuration("trash.retention"),
+		Interval:  cnf.GetDuration("trash.interval"),
+		Batch:     cnf.GetInt("trash.batch"),
+	})
 }
 
 func CORSMiddleware() gin.HandlerFunc {
 	return func(g *gin.Context) {
 		g.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		g.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		g.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		g.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		g.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Short-Password, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata")
+		g.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, HEAD, PATCH")
+		g.Writer.Header().Set("Access-Control-Expose-Headers", "X-Claim-Token, Location, Tus-Resumable, Upload-Offset, Upload-Length, Upload-Short-Url")
 
 		if g.Request.Method == "OPTIONS" {
 			g.AbortWithStatus(204)
@@ -92,16 +220,25 @@ func Run() {
 	configInit()
 	logInit()
 	database := dbConn()
-	gcsClient := gcsConn()
+	blobStore := blobConn()
+	legacyStore := legacyConn(blobStore)
 	botClient := lineBotConn()
-	serviceBuild(database, gcsClient)
+	serviceBuild(database, blobStore, legacyStore)
+	migrated, err := mediastore.MediaStoreService.MigrateLegacy(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	log.Println("mediastore: moved", migrated, "media of the former media service")
+	sweeper.SweeperService.Start()
 
 	g := gin.Default()
 	g.Use(CORSMiddleware())
 	router.NewUserRouter(g.Group("api/user"))
-	router.NewMediaRouter(g.Group("api/media"))
-	router.NewShortRouter(g.Group("api/short"))
+	router.NewMediaRouter(g.Group("api/media"), cnf)
+	router.NewShortRouter(g.Group("api/short"), cnf)
 	router.NewLineRouter(g.Group("api/line"), botClient, cnf)
+	router.NewStatusRouter(g.Group("api/status"))
 	g.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.NewRedirectRouter(g.Group(""), cnf)
 	g.Run(":8888")
 }
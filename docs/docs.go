@@ -58,13 +58,207 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "headers": {
+                            "X-Claim-Token": {
+                                "type": "string",
+                                "description": "claim token, only for uploads without Authorization"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/media/upload": {
+            "post": {
+                "description": "Starts a resumable video upload (tus 1.0.0). Upload-Metadata carries expirationTime and the optional password. The upload is addressed by the returned Location.",
+                "tags": [
+                    "Media"
+                ],
+                "summary": "CreateUpload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "size of the video in bytes",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "expirationTime and password, base64 encoded",
+                        "name": "Upload-Metadata",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "address of the upload"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/media/upload/{id}": {
+            "delete": {
+                "description": "Abandons an unfinished upload.",
+                "tags": [
+                    "Media"
+                ],
+                "summary": "DeleteUpload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "head": {
+                "description": "Returns how much of the upload has arrived, to resume it from Upload-Offset.",
+                "tags": [
+                    "Media"
+                ],
+                "summary": "UploadOffset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "headers": {
+                            "Upload-Length": {
+                                "type": "string",
+                                "description": "size of the video in bytes"
+                            },
+                            "Upload-Offset": {
+                                "type": "string",
+                                "description": "bytes received"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Appends the body at Upload-Offset. The request that completes the upload returns the short code of the media in Upload-Short-Url.",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "UploadChunk",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "offset of the chunk",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "Upload-Offset": {
+                                "type": "string",
+                                "description": "bytes received"
+                            },
+                            "Upload-Short-Url": {
+                                "type": "string",
+                                "description": "short code, once the upload is complete"
+                            },
+                            "X-Claim-Token": {
+                                "type": "string",
+                                "description": "claim token, only for uploads without Authorization"
+                            }
+                        }
                     }
                 }
             }
         },
         "/api/media/video": {
             "post": {
+                "description": "The video is streamed into storage while it is received. For large files use the resumable upload.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -105,13 +299,20 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "headers": {
+                            "X-Claim-Token": {
+                                "type": "string",
+                                "description": "claim token, only for uploads without Authorization"
+                            }
+                        }
                     }
                 }
             }
         },
         "/api/media/{short}": {
             "get": {
+                "description": "url is a signed download url that is valid until urlExpireTime, at most until the media expires.",
                 "consumes": [
                     "application/json"
                 ],
@@ -150,6 +351,134 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/media/{short}/content": {
+            "get": {
+                "description": "Serves the content of media, the password and expiration are checked like for GetMedia. Supports Range, If-Range and conditional requests.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "MediaContent",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "short",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "password",
+                        "name": "password",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/media/{short}/download": {
+            "get": {
+                "description": "Serves media through the signed url returned by GetMedia, for storage backends that cannot sign urls themselves. Supports Range, If-Range and conditional requests.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "DownloadMedia",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "expires",
+                        "name": "expires",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "signature",
+                        "name": "signature",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/media/{short}/qr": {
+            "get": {
+                "produces": [
+                    "image/png",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "Media"
+                ],
+                "summary": "MediaQRCode",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "png or svg, default png",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "width in pixels, 64 to 2048, default 256",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "quiet zone in modules, 0 to 16, default 4",
+                        "name": "margin",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "error correction L, M, Q or H, default M",
+                        "name": "level",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
         "/api/short": {
             "post": {
                 "consumes": [
@@ -186,10 +515,11 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/short/{short}": {
-            "get": {
+        "/api/short/batch": {
+            "post": {
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
@@ -197,14 +527,869 @@ const docTemplate = `{
                 "tags": [
                     "Short"
                 ],
-                "summary": "GetShort",
+                "summary": "ShortBatch",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "short",
-                        "name": "short",
-                        "in": "path",
-                        "required": true
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header"
+                    },
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/router.ShortInfo"
+                            }
+                        }
+                    },
+                    {
+                        "type": "file",
+                        "description": "csv with a header row: leadUrl,alias,activatesAt,expiresAt,password",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/short/{short}": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Short"
+                ],
+                "summary": "GetShort",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "custom domain of the link",
+                        "name": "domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "password",
+                        "name": "password",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "password",
+                        "name": "X-Short-Password",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/short/{short}/qr": {
+            "get": {
+                "produces": [
+                    "image/png",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "Short"
+                ],
+                "summary": "ShortQRCode",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "png or svg, default png",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "width in pixels, 64 to 2048, default 256",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "quiet zone in modules, 0 to 16, default 4",
+                        "name": "margin",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "error correction L, M, Q or H, default M",
+                        "name": "level",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/status/sweeper": {
+            "get": {
+                "description": "Counts of the expired media the sweeper of this instance removed since it started.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Status"
+                ],
+                "summary": "SweeperStats",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/claim": {
+            "post": {
+                "description": "Attaches short links and media created without Authorization to the account, using the claim tokens returned when they were created.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Claim",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.ClaimInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/domain": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "DomainList",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "post": {
+                "description": "Registers a custom domain. Publish the returned TXT record and call VerifyDomain, then point the domain at this server to serve short links created with it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "AddDomain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.DomainInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/domain/{host}": {
+            "delete": {
+                "description": "Domains can only be removed once no short link uses them anymore, links in the trash count until they are purged.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "DeleteDomain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "host",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/domain/{host}/verify": {
+            "post": {
+                "description": "Checks the TXT record of the domain. The first account to verify a domain keeps it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "VerifyDomain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "host",
+                        "name": "host",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/login": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Login",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.LoginInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/media": {
+            "get": {
+                "description": "Pages with the opaque next token of the previous response instead of page numbers, next is empty on the last page.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "MediaCursorList",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "next of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "1 to 100, default 20",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/media/{page}/{limit}": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "MediaList",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page",
+                        "name": "page",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/media/{shortId}": {
+            "put": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "UpdateMedia",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.UpdateMediaInfo"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "shortId",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "delete": {
+                "description": "Moves the media to the trash, it can be restored until purgeTime.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "DeleteMedia",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "shortId",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/media/{shortId}/restore": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "RestoreMedia",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "shortId",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/register": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Register",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/router.RegisterInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/short": {
+            "get": {
+                "description": "Pages with the opaque next token of the previous response instead of page numbers, next is empty on the last page. The filters must stay the same between pages.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "ShortCursorList",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "next of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "1 to 100, default 20",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "folder",
+                        "name": "folder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "substring of name, code or destination",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "active, scheduled, expired, exhausted or delete",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ok, broken, unknown or unchecked",
+                        "name": "health",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created at or after, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created before, RFC3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "createTime, -createTime, name or -name, default -createTime",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/short/export": {
+            "get": {
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "ExportShort",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv or json, default json",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "folder",
+                        "name": "folder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "substring of name, code or destination",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "active, scheduled, expired, exhausted or delete",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ok, broken, unknown or unchecked",
+                        "name": "health",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created at or after, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created before, RFC3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "createTime, -createTime, name or -name, default -createTime",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/short/import": {
+            "post": {
+                "description": "Accepts the csv or json of ExportShort and the bit.ly csv link export. Codes are kept when they are free, taken or invalid codes are reported as conflicts and imported under a generated code. Deleted links are skipped.",
+                "consumes": [
+                    "application/json",
+                    "multipart/form-data",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "ImportShort",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "export file",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/api/user/short/{page}/{limit}": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "ShortList",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page",
+                        "name": "page",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "folder",
+                        "name": "folder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "substring of name, code or destination",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "active, scheduled, expired, exhausted or delete",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ok, broken, unknown or unchecked",
+                        "name": "health",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created at or after, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created before, RFC3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "createTime, -createTime, name or -name, default -createTime",
+                        "name": "sort",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -214,8 +1399,8 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/login": {
-            "post": {
+        "/api/user/short/{shortId}": {
+            "put": {
                 "consumes": [
                     "application/json"
                 ],
@@ -225,16 +1410,30 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "Login",
+                "summary": "UpdateShort",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "description": "body",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/router.LoginInfo"
+                            "$ref": "#/definitions/router.UpdateShortInfo"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "shortId",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -242,10 +1441,9 @@ const docTemplate = `{
                         "description": "OK"
                     }
                 }
-            }
-        },
-        "/api/user/media/{page}/{limit}": {
-            "get": {
+            },
+            "delete": {
+                "description": "Moves the short link to the trash, it can be restored until purgeTime.",
                 "consumes": [
                     "application/json"
                 ],
@@ -255,7 +1453,7 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "MediaList",
+                "summary": "DeleteShort",
                 "parameters": [
                     {
                         "type": "string",
@@ -265,16 +1463,9 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "page",
-                        "name": "page",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "limit",
-                        "name": "limit",
+                        "type": "string",
+                        "description": "shortId",
+                        "name": "shortId",
                         "in": "path",
                         "required": true
                     }
@@ -286,8 +1477,8 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/media/{shortId}": {
-            "put": {
+        "/api/user/short/{shortId}/history": {
+            "get": {
                 "consumes": [
                     "application/json"
                 ],
@@ -297,7 +1488,7 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "UpdateMedia",
+                "summary": "ShortHistory",
                 "parameters": [
                     {
                         "type": "string",
@@ -306,15 +1497,6 @@ const docTemplate = `{
                         "in": "header",
                         "required": true
                     },
-                    {
-                        "description": "body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/router.UpdateMediaInfo"
-                        }
-                    },
                     {
                         "type": "string",
                         "description": "shortId",
@@ -328,8 +1510,10 @@ const docTemplate = `{
                         "description": "OK"
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/api/user/short/{shortId}/restore": {
+            "post": {
                 "consumes": [
                     "application/json"
                 ],
@@ -339,7 +1523,7 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "DeleteMedia",
+                "summary": "RestoreShort",
                 "parameters": [
                     {
                         "type": "string",
@@ -363,7 +1547,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/register": {
+        "/api/user/short/{shortId}/rollback": {
             "post": {
                 "consumes": [
                     "application/json"
@@ -374,16 +1558,30 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "Register",
+                "summary": "RollbackShort",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Authorization",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "description": "body",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/router.RegisterInfo"
+                            "$ref": "#/definitions/router.RollbackShortInfo"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "shortId",
+                        "name": "shortId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -393,7 +1591,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/short/{page}/{limit}": {
+        "/api/user/short/{shortId}/stats": {
             "get": {
                 "consumes": [
                     "application/json"
@@ -404,7 +1602,7 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "ShortList",
+                "summary": "ShortStats",
                 "parameters": [
                     {
                         "type": "string",
@@ -414,18 +1612,29 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "page",
-                        "name": "page",
+                        "type": "string",
+                        "description": "shortId",
+                        "name": "shortId",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "limit",
-                        "name": "limit",
-                        "in": "path",
-                        "required": true
+                        "type": "string",
+                        "description": "RFC3339, default 30 days ago",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339, default now",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "hour or day, default day",
+                        "name": "interval",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -435,8 +1644,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/user/short/{shortId}": {
-            "put": {
+        "/api/user/trash": {
+            "get": {
+                "description": "Deleted short links and media that can still be restored, most recently deleted first.",
                 "consumes": [
                     "application/json"
                 ],
@@ -446,7 +1656,7 @@ const docTemplate = `{
                 "tags": [
                     "User"
                 ],
-                "summary": "UpdateShort",
+                "summary": "TrashList",
                 "parameters": [
                     {
                         "type": "string",
@@ -456,20 +1666,22 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/router.UpdateShortInfo"
-                        }
+                        "type": "string",
+                        "description": "short or media",
+                        "name": "kind",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "shortId",
-                        "name": "shortId",
-                        "in": "path",
-                        "required": true
+                        "description": "next of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "1 to 100, default 20",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -477,43 +1689,93 @@ const docTemplate = `{
                         "description": "OK"
                     }
                 }
-            },
-            "delete": {
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/{short}": {
+            "get": {
                 "produces": [
-                    "application/json"
+                    "text/html"
                 ],
                 "tags": [
-                    "User"
+                    "Short"
                 ],
-                "summary": "DeleteShort",
+                "summary": "Redirect",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Authorization",
-                        "name": "Authorization",
-                        "in": "header",
+                        "description": "short",
+                        "name": "short",
+                        "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "shortId",
-                        "name": "shortId",
-                        "in": "path",
-                        "required": true
+                        "description": "password",
+                        "name": "password",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "password",
+                        "name": "X-Short-Password",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK"
+                    "301": {
+                        "description": "Moved Permanently"
+                    },
+                    "302": {
+                        "description": "Found"
+                    },
+                    "307": {
+                        "description": "Temporary Redirect"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "410": {
+                        "description": "Gone"
                     }
                 }
             }
         }
     },
     "definitions": {
+        "router.ClaimInfo": {
+            "type": "object",
+            "required": [
+                "tokens"
+            ],
+            "properties": {
+                "tokens": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "router.DomainInfo": {
+            "type": "object",
+            "required": [
+                "host"
+            ],
+            "properties": {
+                "host": {
+                    "type": "string",
+                    "maxLength": 253
+                }
+            }
+        },
         "router.LoginInfo": {
             "type": "object",
             "required": [
@@ -571,14 +1833,103 @@ const docTemplate = `{
                 }
             }
         },
+        "router.RollbackShortInfo": {
+            "type": "object",
+            "required": [
+                "version"
+            ],
+            "properties": {
+                "version": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "router.RuleInfo": {
+            "type": "object",
+            "required": [
+                "variants"
+            ],
+            "properties": {
+                "languages": {
+                    "type": "array",
+                    "maxItems": 10,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "platforms": {
+                    "type": "array",
+                    "maxItems": 6,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "variants": {
+                    "type": "array",
+                    "maxItems": 10,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/router.VariantInfo"
+                    }
+                }
+            }
+        },
         "router.ShortInfo": {
             "type": "object",
             "required": [
                 "leadUrl"
             ],
             "properties": {
+                "activatesAt": {
+                    "type": "string"
+                },
+                "alias": {
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3
+                },
+                "domain": {
+                    "description": "Domain is a verified custom domain of the user to serve the link from.",
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "folder": {
+                    "type": "string",
+                    "maxLength": 30
+                },
+                "forceNew": {
+                    "description": "ForceNew creates a new link even when the user already has a plain\nlink to the same destination.",
+                    "type": "boolean"
+                },
                 "leadUrl": {
                     "type": "string"
+                },
+                "maxClicks": {
+                    "type": "integer",
+                    "maximum": 1000000,
+                    "minimum": 0
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "rules": {
+                    "description": "Rules send matching visitors elsewhere than LeadUrl, the first\nmatching rule wins.",
+                    "type": "array",
+                    "maxItems": 10,
+                    "items": {
+                        "$ref": "#/definitions/router.RuleInfo"
+                    }
+                },
+                "tags": {
+                    "type": "array",
+                    "maxItems": 10,
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
@@ -606,9 +1957,62 @@ const docTemplate = `{
         "router.UpdateShortInfo": {
             "type": "object",
             "properties": {
+                "activatesAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "folder": {
+                    "type": "string",
+                    "maxLength": 30
+                },
+                "leadUrl": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string",
                     "maxLength": 15
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "rules": {
+                    "description": "Rules replace the destination rules, an empty list removes them.",
+                    "type": "array",
+                    "maxItems": 10,
+                    "items": {
+                        "$ref": "#/definitions/router.RuleInfo"
+                    }
+                },
+                "tags": {
+                    "type": "array",
+                    "maxItems": 10,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "router.VariantInfo": {
+            "type": "object",
+            "required": [
+                "leadUrl",
+                "name"
+            ],
+            "properties": {
+                "leadUrl": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "weight": {
+                    "type": "integer",
+                    "maximum": 1000,
+                    "minimum": 0
                 }
             }
         }
@@ -0,0 +1,212 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Click struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ShortUrl  string             `bson:"shortUrl" json:"shortUrl"`
+	Time      time.Time          `bson:"time" json:"time"`
+	Referrer  string             `bson:"referrer" json:"referrer"`
+	UserAgent string             `bson:"userAgent" json:"userAgent"`
+	Device    string             `bson:"device" json:"device"`
+	Browser   string             `bson:"browser" json:"browser"`
+	// Variant is the rule variant the visitor was sent to, empty for the
+	// default destination.
+	Variant string `bson:"variant" json:"variant"`
+	IpHash  string `bson:"ipHash" json:"ipHash"`
+}
+
+type Bucket struct {
+	Key   string `bson:"_id" json:"key"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+type Stats struct {
+	Total     int64    `json:"total"`
+	Series    []Bucket `json:"series"`
+	Referrers []Bucket `json:"referrers"`
+	Devices   []Bucket `json:"devices"`
+	Browsers  []Bucket `json:"browsers"`
+	Variants  []Bucket `json:"variants"`
+}
+
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+var intervalFormat = map[string]string{
+	IntervalHour: "%Y-%m-%dT%H:00:00Z",
+	IntervalDay:  "%Y-%m-%d",
+}
+
+type analyticsService struct {
+	collection *mongo.Collection
+	salt       string
+}
+
+var AnalyticsService *analyticsService
+
+func NewAnalyticsService(database *mongo.Database, salt string) {
+	s := &analyticsService{
+		collection: database.Collection("click"),
+		salt:       salt,
+	}
+
+	// stats, export and purge all read the clicks of one code by time
+	_, err := s.collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "shortUrl", Value: 1}, {Key: "time", Value: 1}},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	AnalyticsService = s
+}
+
+// RecordClick stores one resolution of a short code. The visitor ip is only
+// kept as a salted hash.
+func (s *analyticsService) RecordClick(ctx context.Context, shortUrl, variant, referrer, userAgent, ip string) error {
+	device, browser := ClassifyUserAgent(userAgent)
+	sum := sha256.Sum256([]byte(s.salt + ip))
+
+	_, err := s.collection.InsertOne(ctx, &Click{
+		ShortUrl:  shortUrl,
+		Time:      time.Now(),
+		Referrer:  referrer,
+		UserAgent: userAgent,
+		Device:    device,
+		Browser:   browser,
+		Variant:   variant,
+		IpHash:    hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+func (s *analyticsService) ShortStats(ctx context.Context, shortUrl string, from, to time.Time, interval string) (*Stats, error) {
+	format, ok := intervalFormat[interval]
+	if !ok {
+		return nil, model.ErrParameter
+	}
+
+	countBy := func(field string, limit int64) bson.A {
+		return bson.A{
+			bson.M{"$group": bson.M{"_id": field, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.M{"count": -1}},
+			bson.M{"$limit": limit},
+		}
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"shortUrl": shortUrl,
+			"time":     bson.M{"$gte": from, "$lt": to},
+		}},
+		bson.M{"$facet": bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"series": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"$dateToString": bson.M{"format": format, "date": "$time"}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+			"referrers": countBy("$referrer", 10),
+			"devices":   countBy("$device", 10),
+			"browsers":  countBy("$browser", 10),
+			"variants":  countBy("$variant", 20),
+		}},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Series    []Bucket `bson:"series"`
+		Referrers []Bucket `bson:"referrers"`
+		Devices   []Bucket `bson:"devices"`
+		Browsers  []Bucket `bson:"browsers"`
+		Variants  []Bucket `bson:"variants"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	stats := &Stats{
+		Series:    []Bucket{},
+		Referrers: []Bucket{},
+		Devices:   []Bucket{},
+		Browsers:  []Bucket{},
+		Variants:  []Bucket{},
+	}
+	if len(result) == 0 {
+		return stats, nil
+	}
+
+	if len(result[0].Total) > 0 {
+		stats.Total = result[0].Total[0].Count
+	}
+	stats.Series = append(stats.Series, result[0].Series...)
+	stats.Referrers = append(stats.Referrers, result[0].Referrers...)
+	stats.Devices = append(stats.Devices, result[0].Devices...)
+	stats.Browsers = append(stats.Browsers, result[0].Browsers...)
+	stats.Variants = append(stats.Variants, result[0].Variants...)
+
+	return stats, nil
+}
+
+// CountClicks returns the number of recorded clicks of each short code, codes
+// without clicks are left out.
+func (s *analyticsService) CountClicks(ctx context.Context, shortUrls []string) (map[string]int64, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"shortUrl": bson.M{"$in": shortUrls}}},
+		bson.M{"$group": bson.M{"_id": "$shortUrl", "count": bson.M{"$sum": 1}}},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	var buckets []Bucket
+	if err = cursor.All(ctx, &buckets); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	counts := make(map[string]int64, len(buckets))
+	for _, b := range buckets {
+		counts[b.Key] = b.Count
+	}
+
+	return counts, nil
+}
+
+func (s *analyticsService) DeleteClicks(ctx context.Context, shortUrl string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"shortUrl": shortUrl})
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package analytics
+
+import "strings"
+
+const (
+	DeviceBot     = "bot"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceUnknown = "unknown"
+)
+
+// ClassifyUserAgent reduces a user agent to a coarse device class and browser
+// family. It only needs to be good enough for aggregated charts.
+func ClassifyUserAgent(userAgent string) (device, browser string) {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case ua == "":
+		device = DeviceUnknown
+	case containsAny(ua, "bot", "crawler", "spider", "preview", "curl", "wget"):
+		device = DeviceBot
+	case containsAny(ua, "ipad", "tablet") || (strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		device = DeviceTablet
+	case containsAny(ua, "mobile", "iphone", "ipod", "android"):
+		device = DeviceMobile
+	default:
+		device = DeviceDesktop
+	}
+
+	switch {
+	case strings.Contains(ua, "line/"):
+		browser = "LINE"
+	case strings.Contains(ua, "edg"):
+		browser = "Edge"
+	case containsAny(ua, "opr/", "opera"):
+		browser = "Opera"
+	case containsAny(ua, "chrome", "crios"):
+		browser = "Chrome"
+	case containsAny(ua, "firefox", "fxios"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari"):
+		browser = "Safari"
+	default:
+		browser = "Other"
+	}
+
+	return device, browser
+}
+
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMac     = "mac"
+	PlatformLinux   = "linux"
+	PlatformOther   = "other"
+)
+
+// Platform returns the operating system family of a user agent.
+func Platform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case containsAny(ua, "iphone", "ipad", "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "windows"):
+		return PlatformWindows
+	case containsAny(ua, "macintosh", "mac os x"):
+		return PlatformMac
+	case strings.Contains(ua, "linux"):
+		return PlatformLinux
+	default:
+		return PlatformOther
+	}
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
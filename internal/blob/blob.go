@@ -0,0 +1,34 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	BackendGCS   = "gcs"
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Store keeps the content of media. Keys are slash separated paths.
+type Store interface {
+	// Put streams r into the object key and returns its size. When r fails
+	// the object is not created and the error of r is returned.
+	Put(ctx context.Context, key, contentType string, r io.Reader) (int64, error)
+	// Open returns a reader for the object key, model.ErrObjectNotFound when
+	// there is none.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// OpenRange reads the object key from offset, length bytes or up to the
+	// end when length is negative.
+	OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Delete removes the object key. A missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Signer is implemented by stores that can hand out urls reading an object
+// directly from the store until expires.
+type Signer interface {
+	SignedURL(key string, expires time.Time) (string, error)
+}
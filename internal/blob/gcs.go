@@ -0,0 +1,81 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"privaTutle/model"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+type gcsStore struct {
+	bucket *storage.BucketHandle
+}
+
+// NewGCSStore keeps objects in a Google Cloud Storage bucket.
+func NewGCSStore(client *storage.Client, bucket string) Store {
+	return &gcsStore{bucket: client.Bucket(bucket)}
+}
+
+func (s *gcsStore) Put(ctx context.Context, key, contentType string, r io.Reader) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	writer := s.bucket.Object(key).NewWriter(ctx)
+	writer.ContentType = contentType
+	size, err := io.Copy(writer, r)
+	if err != nil {
+		// cancelling the context before Close abandons the object
+		cancel()
+		writer.Close()
+		return 0, err
+	}
+	if err = writer.Close(); err != nil {
+		return 0, model.ErrInternal
+	}
+
+	return size, nil
+}
+
+func (s *gcsStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := s.bucket.Object(key).NewReader(ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, model.ErrObjectNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return reader, nil
+}
+
+func (s *gcsStore) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := s.bucket.Object(key).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, model.ErrObjectNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return reader, nil
+}
+
+func (s *gcsStore) Delete(ctx context.Context, key string) error {
+	err := s.bucket.Object(key).Delete(ctx)
+	if err != nil && err != storage.ErrObjectNotExist {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+// SignedURL signs a V4 url with the service account of the client.
+func (s *gcsStore) SignedURL(key string, expires time.Time) (string, error) {
+	return s.bucket.SignedURL(key, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: expires,
+	})
+}
@@ -0,0 +1,124 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"privaTutle/model"
+	"strings"
+)
+
+type localStore struct {
+	dir string
+}
+
+// NewLocalStore keeps objects as files below dir, for development and single
+// server installations.
+func NewLocalStore(dir string) (Store, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &localStore{dir: dir}, nil
+}
+
+// path maps key below dir, keys that would leave it are refused.
+func (s *localStore) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, s.dir+string(filepath.Separator)) {
+		return "", model.ErrParameter
+	}
+	return path, nil
+}
+
+func (s *localStore) Put(ctx context.Context, key, contentType string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, model.ErrInternal
+	}
+
+	// the content is written to a temporary file first, so readers never see
+	// a partial object
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, model.ErrInternal
+	}
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err = file.Close(); err != nil {
+		return 0, model.ErrInternal
+	}
+	if err = os.Rename(file.Name(), path); err != nil {
+		return 0, model.ErrInternal
+	}
+
+	return size, nil
+}
+
+func (s *localStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, model.ErrObjectNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return file, nil
+}
+
+func (s *localStore) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := s.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	file := reader.(*os.File)
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, model.ErrInternal
+	}
+	if length < 0 {
+		return file, nil
+	}
+
+	return &limitedFile{Reader: io.LimitReader(file, length), file: file}, nil
+}
+
+type limitedFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f *limitedFile) Close() error {
+	return f.file.Close()
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return model.ErrInternal
+	}
+
+	return nil
+}
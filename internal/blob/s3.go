@@ -0,0 +1,328 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"privaTutle/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	// Endpoint is the base url of the service, e.g. https://s3.amazonaws.com
+	// or http://localhost:9000 for MinIO.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket in the path instead of the host name,
+	// which most self hosted stores need.
+	PathStyle bool
+}
+
+type s3Store struct {
+	cnf      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// s3PartSize is the size of the parts content is uploaded in. It is held in
+// memory once per running upload and has to be at least 5 MiB.
+const s3PartSize = 8 << 20
+
+// NewS3Store keeps objects in a bucket of an S3 compatible store. Requests are
+// signed with AWS signature version 4.
+func NewS3Store(cnf S3Config) (Store, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cnf.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || cnf.Bucket == "" {
+		return nil, errors.New("blob: invalid s3 endpoint or bucket")
+	}
+	if cnf.Region == "" {
+		cnf.Region = "us-east-1"
+	}
+
+	return &s3Store{
+		cnf:      cnf,
+		endpoint: endpoint,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key, contentType string, r io.Reader) (int64, error) {
+	buf := make([]byte, s3PartSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		header := http.Header{"Content-Type": {contentType}}
+		resp, err := s.do(ctx, http.MethodPut, s.objectURL(key, nil), header, buf[:n])
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return int64(n), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	uploadId, err := s.createMultipart(ctx, key, contentType)
+	if err != nil {
+		return 0, err
+	}
+	abort := func() {
+		query := url.Values{"uploadId": {uploadId}}
+		if resp, err := s.do(context.Background(), http.MethodDelete, s.objectURL(key, query), nil, nil); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	var parts []s3Part
+	var size int64
+	last := false
+	for number := 1; ; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadId}}
+		resp, err := s.do(ctx, http.MethodPut, s.objectURL(key, query), nil, buf[:n])
+		if err != nil {
+			abort()
+			return 0, err
+		}
+		resp.Body.Close()
+		parts = append(parts, s3Part{Number: number, ETag: resp.Header.Get("ETag")})
+		size += int64(n)
+		if last {
+			break
+		}
+
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		last = err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			abort()
+			return 0, err
+		}
+	}
+
+	if err = s.completeMultipart(ctx, key, uploadId, parts); err != nil {
+		abort()
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func (s *s3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key, nil), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (s *s3Store) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	byteRange := "bytes=" + strconv.FormatInt(offset, 10) + "-"
+	if length >= 0 {
+		if length == 0 {
+			return io.NopCloser(strings.NewReader("")), nil
+		}
+		byteRange += strconv.FormatInt(offset+length-1, 10)
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key, nil), http.Header{"Range": {byteRange}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key, nil), nil, nil)
+	if err != nil && err != model.ErrObjectNotFound {
+		return err
+	}
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	return nil
+}
+
+type s3Part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+func (s *s3Store) createMultipart(ctx context.Context, key, contentType string) (string, error) {
+	header := http.Header{"Content-Type": {contentType}}
+	resp, err := s.do(ctx, http.MethodPost, s.objectURL(key, url.Values{"uploads": {""}}), header, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	result := struct {
+		UploadId string `xml:"UploadId"`
+	}{}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadId == "" {
+		return "", model.ErrInternal
+	}
+
+	return result.UploadId, nil
+}
+
+func (s *s3Store) completeMultipart(ctx context.Context, key, uploadId string, parts []s3Part) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	resp, err := s.do(ctx, http.MethodPost, s.objectURL(key, url.Values{"uploadId": {uploadId}}), nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the completion can fail after the status line was sent as 200, the
+	// error is in the body then
+	result := struct {
+		XMLName xml.Name
+	}{}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.XMLName.Local == "Error" {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+// objectURL addresses key in the bucket. Path and query are encoded the way
+// the signature expects them.
+func (s *s3Store) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	path := "/" + key
+	if s.cnf.PathStyle {
+		path = "/" + s.cnf.Bucket + path
+	} else {
+		u.Host = s.cnf.Bucket + "." + u.Host
+	}
+	u.Path = u.Path + path
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	u.RawPath = strings.Join(segments, "/")
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, awsEscape(k)+"="+awsEscape(query.Get(k)))
+	}
+	u.RawQuery = strings.Join(pairs, "&")
+
+	return &u
+}
+
+// do sends a signed request and turns error statuses into errors. The caller
+// closes the body of a successful response.
+func (s *s3Store) do(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, model.ErrInternal
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, model.ErrObjectNotFound
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, model.ErrInternal
+	}
+
+	return resp, nil
+}
+
+// sign adds the AWS signature version 4 authorization to req.
+func (s *s3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + s.cnf.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cnf.SecretKey), date)
+	key = hmacSHA256(key, s.cnf.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cnf.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscape percent-encodes everything but the unreserved characters, as
+// the signature requires.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package canonical
+
+import (
+	"net"
+	"net/url"
+	"privaTutle/model"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// defaultPorts are dropped from urls of their scheme.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// trackingParams are the query parameters that only identify where a visitor
+// came from. Parameters starting with utm_ are tracking parameters as well.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"_ga":     true,
+	"_gl":     true,
+}
+
+// URL returns the form of rawUrl that equal destinations share: the scheme and
+// host are lowercased, internationalized hosts are converted to punycode,
+// default ports are removed, an empty path becomes "/" and an empty query is
+// dropped. With stripTracking, tracking parameters are removed and the
+// remaining query is sorted by key.
+func URL(rawUrl string, stripTracking bool) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || u.Host == "" {
+		return "", model.ErrParameter
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := u.Hostname(), u.Port()
+	host = strings.TrimSuffix(host, ".")
+	if net.ParseIP(host) == nil {
+		host, err = idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", model.ErrParameter
+		}
+	}
+	host = strings.ToLower(host)
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+
+	if stripTracking && u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+				query.Del(key)
+			}
+		}
+		// Encode sorts by key
+		u.RawQuery = query.Encode()
+	}
+	if u.RawQuery == "" {
+		u.ForceQuery = false
+	}
+
+	return u.String(), nil
+}
@@ -0,0 +1,65 @@
+package canonical
+
+import (
+	"privaTutle/model"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		rawUrl        string
+		stripTracking bool
+		want          string
+		err           error
+	}{
+		{"lowercase scheme and host", "HTTPS://Example.COM/Path", false, "https://example.com/Path", nil},
+		{"empty path", "https://example.com", false, "https://example.com/", nil},
+		{"default port", "https://example.com:443/a", false, "https://example.com/a", nil},
+		{"other port", "http://example.com:8080/a", false, "http://example.com:8080/a", nil},
+		{"http default port", "http://example.com:80/", false, "http://example.com/", nil},
+		{"trailing dot", "https://example.com./a", false, "https://example.com/a", nil},
+		{"idn host", "https://bücher.example/", false, "https://xn--bcher-kva.example/", nil},
+		{"ipv6 host", "http://[::1]:8080/", false, "http://[::1]:8080/", nil},
+		{"empty query", "https://example.com/a?", false, "https://example.com/a", nil},
+		{"surrounding space", "  https://example.com/a  ", false, "https://example.com/a", nil},
+		{"fragment kept", "https://example.com/a#top", false, "https://example.com/a#top", nil},
+		{"tracking kept", "https://example.com/?utm_source=x&b=2&a=1", false, "https://example.com/?utm_source=x&b=2&a=1", nil},
+		{"tracking stripped and sorted", "https://example.com/?utm_source=x&b=2&fbclid=y&a=1", true, "https://example.com/?a=1&b=2", nil},
+		{"tracking case", "https://example.com/?UTM_Medium=x&GCLID=y", true, "https://example.com/", nil},
+		{"only tracking", "https://example.com/a?utm_campaign=x", true, "https://example.com/a", nil},
+		{"no host", "/relative/path", false, "", model.ErrParameter},
+		{"unparsable", "http://%41:8080/", false, "", model.ErrParameter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := URL(tt.rawUrl, tt.stripTracking)
+			if err != tt.err {
+				t.Fatalf("URL(%q) error = %v, want %v", tt.rawUrl, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("URL(%q) = %q, want %q", tt.rawUrl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLIdempotent(t *testing.T) {
+	for _, rawUrl := range []string{
+		"HTTPS://Example.COM:443?b=2&utm_source=x&a=1",
+		"https://bücher.example/a b",
+		"http://[::1]/",
+	} {
+		once, err := URL(rawUrl, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		twice, err := URL(once, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if once != twice {
+			t.Errorf("URL(URL(%q)) = %q, want %q", rawUrl, twice, once)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package claim
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	KindShort = "short"
+	KindMedia = "media"
+)
+
+// Claim ties an anonymously created short link or media to a secret token,
+// so it can be attached to an account later.
+type Claim struct {
+	Id         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind       string             `bson:"kind" json:"kind"`
+	ShortUrl   string             `bson:"shortUrl" json:"shortUrl"`
+	TokenHash  string             `bson:"tokenHash" json:"-"`
+	UserId     string             `bson:"userId" json:"userId"`
+	CreateTime time.Time          `bson:"createTime" json:"createTime"`
+	ExpireTime time.Time          `bson:"expireTime" json:"expireTime"`
+	ClaimTime  time.Time          `bson:"claimTime,omitempty" json:"claimTime"`
+}
+
+type claimService struct {
+	collection *mongo.Collection
+	ttl        time.Duration
+}
+
+var ClaimService *claimService
+
+// NewClaimService keeps tokens claimable for ttl after the item was created.
+func NewClaimService(database *mongo.Database, ttl time.Duration) {
+	ClaimService = &claimService{
+		collection: database.Collection("claim"),
+		ttl:        ttl,
+	}
+}
+
+// NewClaim returns the token for an anonymous item. Only a hash of the token
+// is stored, the token itself is handed out once.
+func (s *claimService) NewClaim(ctx context.Context, kind, shortUrl string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", model.ErrInternal
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	_, err := s.collection.InsertOne(ctx, &Claim{
+		Kind:       kind,
+		ShortUrl:   shortUrl,
+		TokenHash:  hashToken(token),
+		CreateTime: now,
+		ExpireTime: now.Add(s.ttl),
+	})
+	if err != nil {
+		return "", model.ErrInternal
+	}
+
+	return token, nil
+}
+
+// Claim attaches the item of token to objectId. A token can only be used once.
+func (s *claimService) Claim(ctx context.Context, objectId, token string) (*Claim, error) {
+	now := time.Now()
+	filter := bson.M{
+		"tokenHash":  hashToken(token),
+		"userId":     "",
+		"expireTime": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"userId": objectId, "claimTime": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Claim{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrClaimNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// Release makes a claimed token usable again, for claims whose item could not
+// be attached to the account.
+func (s *claimService) Release(ctx context.Context, objectId, token string) error {
+	filter := bson.M{"tokenHash": hashToken(token), "userId": objectId}
+	update := bson.M{"$set": bson.M{"userId": ""}, "$unset": bson.M{"claimTime": ""}}
+	_, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+// ServiceOwner returns the owner id the short service knows an item by. It
+// keeps the empty owner of anonymous items, so for items objectId has claimed
+// that is "", otherwise objectId itself.
+func (s *claimService) ServiceOwner(ctx context.Context, objectId, kind, shortUrl string) (string, error) {
+	if objectId == "" {
+		return "", nil
+	}
+
+	filter := bson.M{"kind": kind, "shortUrl": shortUrl, "userId": objectId}
+	err := s.collection.FindOne(ctx, filter).Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return objectId, nil
+		}
+		return "", model.ErrInternal
+	}
+
+	return "", nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,36 @@
+package claim
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHashToken(t *testing.T) {
+	token := "abc"
+	hash := hashToken(token)
+	if hash != hashToken(token) {
+		t.Error("hashToken() is not stable")
+	}
+	if len(hash) != 64 {
+		t.Errorf("hashToken() length = %d, want 64", len(hash))
+	}
+	if strings.Contains(hash, token) {
+		t.Error("hashToken() keeps the token")
+	}
+	if hash == hashToken("abd") {
+		t.Error("hashToken() is the same for different tokens")
+	}
+}
+
+func TestServiceOwnerAnonymous(t *testing.T) {
+	// anonymous items never reach the claim collection
+	s := &claimService{}
+	owner, err := s.ServiceOwner(context.Background(), "", KindShort, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != "" {
+		t.Errorf("ServiceOwner() = %q, want \"\"", owner)
+	}
+}
@@ -0,0 +1,104 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"privaTutle/model"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	StrategyHash    = "hash"
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+)
+
+// DefaultAlphabet leaves out characters that are easily confused with each
+// other when a code is read aloud or typed from print (0/O/o, 1/l/I).
+const DefaultAlphabet = "23456789abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+
+const maxAttempts = 8
+
+// Generator produces candidate codes. attempt starts at 0 and grows each time
+// the previous candidate turned out to be taken.
+type Generator interface {
+	Generate(ctx context.Context, leadUrl string, attempt int) (string, error)
+}
+
+type Config struct {
+	Strategy string
+	Length   int
+	Alphabet string
+	Seed     int64
+}
+
+type codeService struct {
+	generator Generator
+}
+
+var CodeService *codeService
+
+func NewCodeService(database *mongo.Database, cnf Config) {
+	if cnf.Length < 4 {
+		panic(errors.New("codegen: length must be at least 4"))
+	}
+	if cnf.Alphabet == "" {
+		cnf.Alphabet = DefaultAlphabet
+	}
+	if !validAlphabet(cnf.Alphabet) {
+		panic(errors.New("codegen: alphabet must be letters, digits, '-' and '_' without repeated characters"))
+	}
+
+	var generator Generator
+	switch cnf.Strategy {
+	case StrategyHash:
+		generator = &hashGenerator{alphabet: cnf.Alphabet, length: cnf.Length}
+	case StrategyRandom:
+		generator = &randomGenerator{alphabet: cnf.Alphabet, length: cnf.Length}
+	case StrategyCounter:
+		generator = newCounterGenerator(database.Collection("counter"), shuffle(cnf.Alphabet, cnf.Seed), cnf.Length)
+	default:
+		panic(errors.New("codegen: unknown strategy " + cnf.Strategy))
+	}
+
+	CodeService = &codeService{generator: generator}
+}
+
+// NewCode asks the configured generator for candidates and hands each to take,
+// which claims the candidate unless it is taken already, until one is claimed.
+func (s *codeService) NewCode(ctx context.Context, leadUrl string, take func(context.Context, string) (bool, error)) (string, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		code, err := s.generator.Generate(ctx, leadUrl, attempt)
+		if err != nil {
+			return "", err
+		}
+
+		taken, err := take(ctx, code)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+
+	return "", model.ErrShortCodeExhausted
+}
+
+// validAlphabet accepts the characters custom aliases are made of, others
+// would break urls or the code@host keys of custom domains.
+func validAlphabet(alphabet string) bool {
+	seen := map[rune]bool{}
+	for _, r := range alphabet {
+		if !codeChar(r) || seen[r] {
+			return false
+		}
+		seen[r] = true
+	}
+	return len(seen) >= 2
+}
+
+func codeChar(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_'
+}
@@ -0,0 +1,179 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"privaTutle/model"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int64
+		alphabet string
+		length   int
+		truncate bool
+		want     string
+	}{
+		{"zero is padded", 0, "01", 4, false, "0000"},
+		{"binary", 5, "01", 4, false, "0101"},
+		{"fills length", 15, "01", 4, false, "1111"},
+		{"grows past length", 16, "01", 4, false, "10000"},
+		{"truncated keeps the low digits", 16, "01", 4, true, "0000"},
+		{"custom alphabet", 35, "abcdef", 3, false, "aff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encode(big.NewInt(tt.n), tt.alphabet, tt.length, tt.truncate); got != tt.want {
+				t.Errorf("encode(%d) = %q, want %q", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidAlphabet(t *testing.T) {
+	tests := []struct {
+		alphabet string
+		want     bool
+	}{
+		{DefaultAlphabet, true},
+		{"ab", true},
+		{"a", false},
+		{"", false},
+		{"aba", false},
+		{"abç", false},
+		{"ab-_09XZ", true},
+		{"ab@", false},
+		{"ab/", false},
+		{"ab?", false},
+		{"ab#", false},
+		{"ab%", false},
+		{"ab ", false},
+	}
+	for _, tt := range tests {
+		if got := validAlphabet(tt.alphabet); got != tt.want {
+			t.Errorf("validAlphabet(%q) = %v, want %v", tt.alphabet, got, tt.want)
+		}
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	a, b := shuffle(DefaultAlphabet, 42), shuffle(DefaultAlphabet, 42)
+	if a != b {
+		t.Errorf("shuffle is not stable for a seed: %q, %q", a, b)
+	}
+	if len(a) != len(DefaultAlphabet) || !validAlphabet(a) {
+		t.Errorf("shuffle(%q) = %q is not a permutation", DefaultAlphabet, a)
+	}
+	for _, r := range DefaultAlphabet {
+		if !strings.ContainsRune(a, r) {
+			t.Errorf("shuffle lost %q", r)
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator Generator
+	}{
+		{"hash", &hashGenerator{alphabet: DefaultAlphabet, length: 7}},
+		{"random", &randomGenerator{alphabet: DefaultAlphabet, length: 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.generator.Generate(context.Background(), "https://example.com/", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(code) != 7 {
+				t.Errorf("code %q has length %d, want 7", code, len(code))
+			}
+			for _, r := range code {
+				if !strings.ContainsRune(DefaultAlphabet, r) {
+					t.Errorf("code %q has %q outside the alphabet", code, r)
+				}
+			}
+		})
+	}
+}
+
+func TestHashGenerator(t *testing.T) {
+	h := &hashGenerator{alphabet: DefaultAlphabet, length: 7}
+	ctx := context.Background()
+
+	first, _ := h.Generate(ctx, "https://example.com/", 0)
+	again, _ := h.Generate(ctx, "https://example.com/", 0)
+	retry, _ := h.Generate(ctx, "https://example.com/", 1)
+	other, _ := h.Generate(ctx, "https://example.org/", 0)
+	if first != again {
+		t.Errorf("same destination gave %q and %q", first, again)
+	}
+	if first == retry {
+		t.Errorf("retry gave the same code %q", first)
+	}
+	if first == other {
+		t.Errorf("different destinations gave the same code %q", first)
+	}
+}
+
+func TestCounterScatter(t *testing.T) {
+	// the multiplier is coprime to the code space, so scattering the
+	// sequence never repeats a code
+	c := newCounterGenerator(nil, "0123", 4)
+	seen := map[string]bool{}
+	for seq := int64(0); seq < 256; seq++ {
+		n := big.NewInt(seq)
+		n.Mul(n, c.multiplier).Mod(n, c.space)
+		code := encode(n, c.alphabet, c.length, false)
+		if seen[code] {
+			t.Fatalf("sequence %d repeats code %q", seq, code)
+		}
+		seen[code] = true
+	}
+}
+
+// sequence hands out fixed candidates.
+type sequence []string
+
+func (s sequence) Generate(ctx context.Context, leadUrl string, attempt int) (string, error) {
+	if attempt >= len(s) {
+		return "", errors.New("out of candidates")
+	}
+	return s[attempt], nil
+}
+
+func TestNewCode(t *testing.T) {
+	errTake := errors.New("take failed")
+	tests := []struct {
+		name  string
+		taken map[string]bool
+		err   error
+		want  string
+		fails error
+	}{
+		{"first free", map[string]bool{}, nil, "a", nil},
+		{"skips taken", map[string]bool{"a": true, "b": true}, nil, "c", nil},
+		{"take error", map[string]bool{}, errTake, "", errTake},
+		{"exhausted", map[string]bool{"a": true, "b": true, "c": true, "d": true, "e": true, "f": true, "g": true, "h": true}, nil, "", model.ErrShortCodeExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &codeService{generator: sequence{"a", "b", "c", "d", "e", "f", "g", "h"}}
+			take := func(ctx context.Context, code string) (bool, error) {
+				return tt.taken[code], tt.err
+			}
+
+			code, err := s.NewCode(context.Background(), "https://example.com/", take)
+			if err != tt.fails {
+				t.Fatalf("NewCode() error = %v, want %v", err, tt.fails)
+			}
+			if code != tt.want {
+				t.Errorf("NewCode() = %q, want %q", code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package codegen
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	mathRand "math/rand"
+	"privaTutle/model"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// hashGenerator derives the code from the destination, salting the hash with
+// the attempt number so a collision can be retried.
+type hashGenerator struct {
+	alphabet string
+	length   int
+}
+
+func (h *hashGenerator) Generate(ctx context.Context, leadUrl string, attempt int) (string, error) {
+	sum := sha256.Sum256([]byte(leadUrl + "#" + strconv.Itoa(attempt)))
+	return encode(new(big.Int).SetBytes(sum[:]), h.alphabet, h.length, true), nil
+}
+
+type randomGenerator struct {
+	alphabet string
+	length   int
+}
+
+func (r *randomGenerator) Generate(ctx context.Context, leadUrl string, attempt int) (string, error) {
+	max := big.NewInt(int64(len(r.alphabet)))
+	code := make([]byte, r.length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", model.ErrInternal
+		}
+		code[i] = r.alphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// counterGenerator encodes a shared sequence with a shuffled alphabet. While
+// the sequence fits in length characters it is first scattered by a
+// multiplier coprime to the code space, so codes never repeat but
+// consecutive codes do not look consecutive either.
+type counterGenerator struct {
+	collection *mongo.Collection
+	alphabet   string
+	length     int
+	space      *big.Int
+	multiplier *big.Int
+}
+
+func newCounterGenerator(collection *mongo.Collection, alphabet string, length int) *counterGenerator {
+	space := new(big.Int).Exp(big.NewInt(int64(len(alphabet))), big.NewInt(int64(length)), nil)
+
+	multiplier := big.NewInt(1580030173)
+	for new(big.Int).GCD(nil, nil, multiplier, space).Cmp(big.NewInt(1)) != 0 {
+		multiplier.Add(multiplier, big.NewInt(2))
+	}
+
+	return &counterGenerator{
+		collection: collection,
+		alphabet:   alphabet,
+		length:     length,
+		space:      space,
+		multiplier: multiplier,
+	}
+}
+
+func (c *counterGenerator) Generate(ctx context.Context, leadUrl string, attempt int) (string, error) {
+	filter := bson.M{"_id": "short"}
+	update := bson.M{"$inc": bson.M{"seq": 1}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := c.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if err != nil {
+		return "", model.ErrInternal
+	}
+
+	n := big.NewInt(counter.Seq)
+	if n.Cmp(c.space) < 0 {
+		n.Mul(n, c.multiplier).Mod(n, c.space)
+	}
+
+	return encode(n, c.alphabet, c.length, false), nil
+}
+
+// encode writes n in base len(alphabet), left padded to length. With truncate
+// set the result is cut to length, otherwise it grows once the space is used up.
+func encode(n *big.Int, alphabet string, length int, truncate bool) string {
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+	n = new(big.Int).Set(n)
+
+	var code []byte
+	for n.Sign() > 0 && (!truncate || len(code) < length) {
+		n.DivMod(n, base, mod)
+		code = append(code, alphabet[mod.Int64()])
+	}
+	for len(code) < length {
+		code = append(code, alphabet[0])
+	}
+
+	for i, j := 0, len(code)-1; i < j; i, j = i+1, j-1 {
+		code[i], code[j] = code[j], code[i]
+	}
+
+	return string(code)
+}
+
+func shuffle(alphabet string, seed int64) string {
+	b := []byte(alphabet)
+	mathRand.New(mathRand.NewSource(seed)).Shuffle(len(b), func(i, j int) {
+		b[i], b[j] = b[j], b[i]
+	})
+	return string(b)
+}
@@ -0,0 +1,328 @@
+package customdomain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"privaTutle/model"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// challengePrefix is prepended to the host to get the name of the TXT
+	// record that proves ownership.
+	challengePrefix = "_privatutle-challenge."
+	// challengeValue prefixes the token in the TXT record.
+	challengeValue = "privatutle-verification="
+)
+
+// Domain is a host a user serves short links from.
+type Domain struct {
+	Id     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Host   string             `bson:"host" json:"host"`
+	UserId string             `bson:"userId" json:"userId"`
+	// Token has to be published in a TXT record before the domain is verified.
+	Token      string    `bson:"token" json:"-"`
+	Verified   bool      `bson:"verified" json:"verified"`
+	VerifyTime time.Time `bson:"verifyTime,omitempty" json:"verifyTime"`
+	CreateTime time.Time `bson:"createTime" json:"createTime"`
+}
+
+// Record returns the name and value of the TXT record that verifies d.
+func (d *Domain) Record() (name, value string) {
+	return challengePrefix + d.Host, challengeValue + d.Token
+}
+
+// Resolver looks up TXT records. *net.Resolver satisfies it, tests can pass
+// a fake.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+type domainService struct {
+	collection *mongo.Collection
+	resolver   Resolver
+	reserved   map[string]bool
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	domain  *Domain
+	expires time.Time
+}
+
+const (
+	// cacheDuration bounds how long the redirect path trusts a host lookup.
+	cacheDuration = time.Minute
+	maxCacheSize  = 10000
+)
+
+var DomainService *domainService
+
+// NewDomainService builds the service. reserved hosts, such as the hosts of
+// this server, can never be registered.
+func NewDomainService(database *mongo.Database, resolver Resolver, reserved []string) {
+	s := &domainService{
+		collection: database.Collection("domain"),
+		resolver:   resolver,
+		reserved:   map[string]bool{},
+		cache:      map[string]cacheEntry{},
+	}
+	for _, host := range reserved {
+		if host = Normalize(host); host != "" {
+			s.reserved[host] = true
+		}
+	}
+
+	// a host is verified for one user at most
+	_, err := s.collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "host", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"verified": true}),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	DomainService = s
+}
+
+// Normalize lowercases a host and strips a port, a trailing dot and, for
+// config values, a url scheme and path.
+func Normalize(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// Key scopes a short code to a custom domain. Codes on the default domain are
+// their own key, codes on a custom domain are stored as code@host. Codes never
+// contain @, so keys of different domains cannot collide.
+func Key(code, host string) string {
+	if host == "" {
+		return code
+	}
+	return code + "@" + host
+}
+
+// SplitKey is the reverse of Key.
+func SplitKey(key string) (code, host string) {
+	if i := strings.LastIndexByte(key, '@'); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+// AddDomain registers host for objectId. The domain only serves links once
+// VerifyDomain succeeded.
+func (s *domainService) AddDomain(ctx context.Context, objectId, host string) (*Domain, error) {
+	host = Normalize(host)
+	if s.reserved[host] {
+		return nil, model.ErrDomainTaken
+	}
+
+	verified, err := s.getVerifiedDomain(ctx, host)
+	if err != nil && err != model.ErrDomainNotFound {
+		return nil, err
+	}
+	if verified != nil {
+		if verified.UserId == objectId {
+			return verified, nil
+		}
+		return nil, model.ErrDomainTaken
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	filter := bson.M{"host": host, "userId": objectId}
+	update := bson.M{"$setOnInsert": bson.M{
+		"token":      hex.EncodeToString(b),
+		"verified":   false,
+		"createTime": time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	data := &Domain{}
+	err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// VerifyDomain looks for the TXT record of a registered domain. The first
+// user to verify a host gets it, other registrations of the host are removed.
+func (s *domainService) VerifyDomain(ctx context.Context, objectId, host string) (*Domain, error) {
+	data, err := s.GetUserDomain(ctx, objectId, host)
+	if err != nil {
+		return nil, err
+	}
+	if data.Verified {
+		return data, nil
+	}
+
+	err = s.checkRecord(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// the unique index on verified hosts decides between concurrent verifications
+	update := bson.M{"$set": bson.M{"verified": true, "verifyTime": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": data.Id}, update, opts).Decode(data)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, model.ErrDomainTaken
+		}
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrDomainNotFound
+		}
+		return nil, model.ErrInternal
+	}
+	_, err = s.collection.DeleteMany(ctx, bson.M{"host": data.Host, "_id": bson.M{"$ne": data.Id}})
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	s.forget(data.Host)
+
+	return data, nil
+}
+
+// checkRecord fails with model.ErrDomainUnverified unless the TXT record of d
+// is published.
+func (s *domainService) checkRecord(ctx context.Context, d *Domain) error {
+	name, value := d.Record()
+	records, err := s.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return model.ErrDomainUnverified
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == value {
+			return nil
+		}
+	}
+
+	return model.ErrDomainUnverified
+}
+
+func (s *domainService) GetUserDomain(ctx context.Context, objectId, host string) (*Domain, error) {
+	data := &Domain{}
+	err := s.collection.FindOne(ctx, bson.M{"host": Normalize(host), "userId": objectId}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrDomainNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *domainService) ListUserDomains(ctx context.Context, objectId string) ([]*Domain, error) {
+	opts := options.Find().SetSort(bson.M{"host": 1})
+	cursor, err := s.collection.Find(ctx, bson.M{"userId": objectId}, opts)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	data := []*Domain{}
+	if err = cursor.All(ctx, &data); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *domainService) DeleteDomain(ctx context.Context, objectId, host string) error {
+	host = Normalize(host)
+	result, err := s.collection.DeleteOne(ctx, bson.M{"host": host, "userId": objectId})
+	if err != nil {
+		return model.ErrInternal
+	}
+	if result.DeletedCount == 0 {
+		return model.ErrDomainNotFound
+	}
+	s.forget(host)
+
+	return nil
+}
+
+// Lookup returns the verified domain serving host, or nil when host is not a
+// custom domain. Results are cached for the redirect path.
+func (s *domainService) Lookup(ctx context.Context, host string) (*Domain, error) {
+	host = Normalize(host)
+	if host == "" || s.reserved[host] {
+		return nil, nil
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	entry, ok := s.cache[host]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.domain, nil
+	}
+
+	data, err := s.getVerifiedDomain(ctx, host)
+	if err != nil && err != model.ErrDomainNotFound {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	// the host header is chosen by the client, so the cache must not grow
+	// without bound
+	if len(s.cache) >= maxCacheSize {
+		s.cache = map[string]cacheEntry{}
+	}
+	s.cache[host] = cacheEntry{domain: data, expires: now.Add(cacheDuration)}
+	s.mu.Unlock()
+
+	return data, nil
+}
+
+// IsCustomDomain reports whether host is a verified custom domain.
+func (s *domainService) IsCustomDomain(ctx context.Context, host string) bool {
+	data, err := s.Lookup(ctx, host)
+	return err == nil && data != nil
+}
+
+func (s *domainService) getVerifiedDomain(ctx context.Context, host string) (*Domain, error) {
+	data := &Domain{}
+	err := s.collection.FindOne(ctx, bson.M{"host": host, "verified": true}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrDomainNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *domainService) forget(host string) {
+	s.mu.Lock()
+	delete(s.cache, host)
+	s.mu.Unlock()
+}
@@ -0,0 +1,83 @@
+package customdomain
+
+import (
+	"context"
+	"errors"
+	"privaTutle/model"
+	"testing"
+)
+
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.records[name], nil
+}
+
+func TestCheckRecord(t *testing.T) {
+	d := &Domain{Host: "go.example.com", Token: "abc123"}
+	name := "_privatutle-challenge.go.example.com"
+
+	tests := []struct {
+		name     string
+		resolver *fakeResolver
+		want     error
+	}{
+		{"published", &fakeResolver{records: map[string][]string{name: {"privatutle-verification=abc123"}}}, nil},
+		{"among others", &fakeResolver{records: map[string][]string{name: {"v=spf1 -all", " privatutle-verification=abc123 "}}}, nil},
+		{"other token", &fakeResolver{records: map[string][]string{name: {"privatutle-verification=other"}}}, model.ErrDomainUnverified},
+		{"other name", &fakeResolver{records: map[string][]string{"go.example.com": {"privatutle-verification=abc123"}}}, model.ErrDomainUnverified},
+		{"no record", &fakeResolver{}, model.ErrDomainUnverified},
+		{"lookup fails", &fakeResolver{err: errors.New("no such host")}, model.ErrDomainUnverified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &domainService{resolver: tt.resolver}
+			if err := s.checkRecord(context.Background(), d); err != tt.want {
+				t.Errorf("checkRecord() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"Go.Example.com", "go.example.com"},
+		{"go.example.com.", "go.example.com"},
+		{"go.example.com:8080", "go.example.com"},
+		{"https://go.example.com/path", "go.example.com"},
+		{" go.example.com ", "go.example.com"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.host); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		code, host, key string
+	}{
+		{"abc", "", "abc"},
+		{"abc", "go.example.com", "abc@go.example.com"},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.code, tt.host); got != tt.key {
+			t.Errorf("Key(%q, %q) = %q, want %q", tt.code, tt.host, got, tt.key)
+		}
+		code, host := SplitKey(tt.key)
+		if code != tt.code || host != tt.host {
+			t.Errorf("SplitKey(%q) = %q, %q, want %q, %q", tt.key, code, host, tt.code, tt.host)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"privaTutle/internal/link"
+	"privaTutle/internal/policy"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Interval is how often due links are looked for, 0 disables the checker.
+	Interval time.Duration
+	// RecheckAfter is how old a check result gets before the link is due again.
+	RecheckAfter time.Duration
+	// Batch is the most links checked per round.
+	Batch int64
+	// Concurrency is how many hosts are checked at the same time.
+	Concurrency int
+	// HostInterval is the pause between two requests to the same host.
+	HostInterval time.Duration
+	// Timeout bounds a single check, redirects included.
+	Timeout time.Duration
+}
+
+type healthService struct {
+	cnf    Config
+	client *http.Client
+}
+
+var HealthService *healthService
+
+const maxRedirects = 5
+
+var errRedirectRefused = errors.New("redirect refused")
+
+func NewHealthService(cnf Config) {
+	if cnf.Batch <= 0 {
+		cnf.Batch = 200
+	}
+	if cnf.Concurrency <= 0 {
+		cnf.Concurrency = 1
+	}
+
+	s := &healthService{
+		cnf: cnf,
+		client: &http.Client{
+			Timeout: cnf.Timeout,
+			// redirects have to pass the url policy like the link itself, so
+			// the checker never ends up requesting internal addresses
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errRedirectRefused
+				}
+				if policy.PolicyService.Check(req.Context(), req.URL.String()) != nil {
+					return errRedirectRefused
+				}
+				return nil
+			},
+		},
+	}
+
+	if cnf.Interval > 0 {
+		go s.run()
+	}
+
+	HealthService = s
+}
+
+func (s *healthService) run() {
+	ticker := time.NewTicker(s.cnf.Interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		if err := s.CheckDue(context.Background()); err != nil {
+			log.Println("health: check links:", err)
+		}
+	}
+}
+
+// CheckDue checks one batch of links that are due. Links are grouped by host,
+// every host is handled by a single worker which waits HostInterval between
+// its requests, and at most Concurrency hosts are worked on at once.
+func (s *healthService) CheckDue(ctx context.Context) error {
+	// claimed links are kept from other instances for as long as the batch
+	// can take when all of it is on one host and every check needs HEAD and GET
+	lease := time.Duration(s.cnf.Batch) * (s.cnf.HostInterval + 2*s.cnf.Timeout)
+	links, err := link.LinkService.ClaimLinksToCheck(ctx, time.Now().Add(-s.cnf.RecheckAfter), lease, s.cnf.Batch)
+	if err != nil {
+		return err
+	}
+
+	hosts := map[string][]*link.Link{}
+	for _, l := range links {
+		host := ""
+		if u, err := url.Parse(l.LeadUrl); err == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+		hosts[host] = append(hosts[host], l)
+	}
+
+	queue := make(chan []*link.Link, len(hosts))
+	for _, group := range hosts {
+		queue <- group
+	}
+	close(queue)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < s.cnf.Concurrency && i < len(hosts); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range queue {
+				s.checkHost(ctx, group)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (s *healthService) checkHost(ctx context.Context, links []*link.Link) {
+	for i, l := range links {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.cnf.HostInterval):
+			}
+		}
+
+		health := s.Check(ctx, l.LeadUrl)
+		if err := link.LinkService.UpdateLinkHealth(ctx, l.ShortUrl, l.LeadUrl, health); err != nil {
+			log.Println("health: store result of", l.ShortUrl+":", err)
+		}
+	}
+}
+
+// Check requests leadUrl with HEAD, falling back to GET for servers that do
+// not support HEAD, and classifies the response.
+func (s *healthService) Check(ctx context.Context, leadUrl string) *link.Health {
+	health := &link.Health{State: link.HealthUnknown, CheckTime: time.Now()}
+	if policy.PolicyService.Check(ctx, leadUrl) != nil {
+		return health
+	}
+
+	status, err := s.request(ctx, http.MethodHead, leadUrl)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = s.request(ctx, http.MethodGet, leadUrl)
+	}
+	if err != nil {
+		if !errors.Is(err, errRedirectRefused) {
+			health.State = link.HealthBroken
+		}
+		return health
+	}
+
+	health.Status = status
+	health.State = state(status)
+	return health
+}
+
+func (s *healthService) request(ctx context.Context, method, leadUrl string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, leadUrl, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "privaTutle-link-checker")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// only the status matters, the body is never read
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// state counts a destination as broken only when the answer clearly says the
+// page is gone or failing. Other client errors, such as 403 or 429, are often
+// servers turning away bots and stay unknown.
+func state(status int) string {
+	switch {
+	case status >= 200 && status < 400:
+		return link.HealthOk
+	case status == http.StatusNotFound || status == http.StatusGone || status >= 500:
+		return link.HealthBroken
+	default:
+		return link.HealthUnknown
+	}
+}
@@ -0,0 +1,82 @@
+package link
+
+import (
+	"context"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	HealthOk      = "ok"
+	HealthBroken  = "broken"
+	HealthUnknown = "unknown"
+	// HealthUnchecked only exists as a list filter, for links without Health.
+	HealthUnchecked = "unchecked"
+)
+
+// Health is the result of the last destination check of a link.
+type Health struct {
+	State string `bson:"state" json:"state"`
+	// Status is the http status of the destination, 0 when there was no response.
+	Status    int       `bson:"status" json:"status"`
+	CheckTime time.Time `bson:"checkTime" json:"checkTime"`
+}
+
+// ClaimLinksToCheck leases up to limit links that resolve and were never
+// checked or were last checked before the given time, least recently checked
+// first. A leased link is skipped by other instances until lease has passed
+// or its result is stored, so a crashed check is picked up again.
+func (s *linkService) ClaimLinksToCheck(ctx context.Context, before time.Time, lease time.Duration, limit int64) ([]*Link, error) {
+	data := []*Link{}
+	for int64(len(data)) < limit {
+		now := time.Now()
+		// shared copies of legacy codes never resolve
+		filter := bson.M{"status": StatusActive, "shared": nil}
+		filter["$and"] = append(available(now),
+			bson.M{"$or": bson.A{
+				bson.M{"health": bson.M{"$exists": false}},
+				bson.M{"health.checkTime": bson.M{"$lt": before}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"healthLeaseTime": bson.M{"$exists": false}},
+				bson.M{"healthLeaseTime": bson.M{"$lt": now}},
+			}},
+		)
+		update := bson.M{"$set": bson.M{"healthLeaseTime": now.Add(lease)}}
+		opts := options.FindOneAndUpdate().SetSort(bson.M{"health.checkTime": 1}).SetReturnDocument(options.After)
+
+		l := &Link{}
+		err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(l)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				break
+			}
+			return nil, model.ErrInternal
+		}
+		data = append(data, l)
+	}
+
+	return data, nil
+}
+
+// UpdateLinkHealth stores a check result and ends the lease of the check. The
+// destination is part of the filter, so a result for a destination that was
+// replaced meanwhile is dropped.
+func (s *linkService) UpdateLinkHealth(ctx context.Context, shortUrl, leadUrl string, health *Health) error {
+	filter := primary(shortUrl)
+	filter["leadUrl"] = leadUrl
+	update := bson.M{
+		"$set":   bson.M{"health": health},
+		"$unset": bson.M{"healthLeaseTime": ""},
+	}
+	_, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package link
+
+import (
+	"context"
+	"privaTutle/internal/canonical"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// History is a destination a link pointed to before it was edited. UserId and
+// CreateTime tell who replaced it and when. History belongs to the link by
+// LinkId, a code that is used again starts without it.
+type History struct {
+	Id         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	LinkId     primitive.ObjectID `bson:"linkId" json:"-"`
+	ShortUrl   string             `bson:"shortUrl" json:"shortUrl"`
+	Version    int64              `bson:"version" json:"version"`
+	LeadUrl    string             `bson:"leadUrl" json:"leadUrl"`
+	UserId     string             `bson:"userId" json:"userId"`
+	CreateTime time.Time          `bson:"createTime" json:"createTime"`
+}
+
+// UpdateLinkLeadUrl points the link at a new destination and keeps the
+// previous one in the history under the version it had. leadUrl is stored in
+// its canonical form.
+func (s *linkService) UpdateLinkLeadUrl(ctx context.Context, objectId, shortUrl, leadUrl string) (*Link, error) {
+	leadUrl, err := canonical.URL(leadUrl, s.stripTracking)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	filter := primary(shortUrl)
+	filter["userId"] = objectId
+	update := bson.M{
+		"$set":   bson.M{"leadUrl": leadUrl, "updateTime": now},
+		"$inc":   bson.M{"version": 1},
+		"$unset": bson.M{"health": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	before := &Link{}
+	err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(before)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, s.missing(ctx, objectId, shortUrl)
+		}
+		return nil, model.ErrInternal
+	}
+
+	_, err = s.historyCollection.InsertOne(ctx, &History{
+		LinkId:     before.Id,
+		ShortUrl:   shortUrl,
+		Version:    before.Version,
+		LeadUrl:    before.LeadUrl,
+		UserId:     objectId,
+		CreateTime: now,
+	})
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+
+	data := before
+	data.LeadUrl = leadUrl
+	data.Version++
+	data.Health = nil
+	data.UpdateTime = now
+
+	return data, nil
+}
+
+// ListLinkHistory returns the previous destinations of the link linkId,
+// latest first.
+func (s *linkService) ListLinkHistory(ctx context.Context, linkId primitive.ObjectID) ([]*History, error) {
+	opts := options.Find().SetSort(bson.M{"version": -1})
+	cursor, err := s.historyCollection.Find(ctx, bson.M{"linkId": linkId}, opts)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	data := []*History{}
+	if err = cursor.All(ctx, &data); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *linkService) GetLinkHistory(ctx context.Context, linkId primitive.ObjectID, version int64) (*History, error) {
+	data := &History{}
+	err := s.historyCollection.FindOne(ctx, bson.M{"linkId": linkId, "version": version}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortVersionNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
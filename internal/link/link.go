@@ -0,0 +1,435 @@
+package link
+
+import (
+	"context"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	StatusActive = "active"
+	StatusDelete = "delete"
+	// StatusPurge marks what is left of a link after the trash was emptied, it
+	// only keeps the code from being handed out again.
+	StatusPurge = "purge"
+)
+
+type Link struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ShortUrl    string             `bson:"shortUrl" json:"shortUrl"`
+	Domain      string             `bson:"domain,omitempty" json:"domain"`
+	UserId      string             `bson:"userId" json:"userId"`
+	LeadUrl     string             `bson:"leadUrl" json:"leadUrl"`
+	Name        string             `bson:"name" json:"name"`
+	Tags        []string           `bson:"tags" json:"tags"`
+	Folder      string             `bson:"folder" json:"folder"`
+	Status      string             `bson:"status" json:"status"`
+	Version     int64              `bson:"version" json:"version"`
+	Password    string             `bson:"password,omitempty" json:"-"`
+	MaxClicks   int64              `bson:"maxClicks,omitempty" json:"maxClicks"`
+	Clicks      int64              `bson:"clicks" json:"clicks"`
+	Remaining   int64              `bson:"-" json:"remaining"`
+	ActivatesAt time.Time          `bson:"activatesAt,omitempty" json:"activatesAt"`
+	ExpiresAt   time.Time          `bson:"expiresAt,omitempty" json:"expiresAt"`
+	Health      *Health            `bson:"health,omitempty" json:"health"`
+	Rules       []Rule             `bson:"rules,omitempty" json:"rules"`
+	CreateTime  time.Time          `bson:"createTime" json:"createTime"`
+	UpdateTime  time.Time          `bson:"updateTime" json:"updateTime"`
+	// Shared is set to the owner on the copies of legacy hash codes, which
+	// several users can have. Copies are only listed and cannot be changed,
+	// the code resolves to the link without Shared or the legacy short.
+	Shared string `bson:"shared,omitempty" json:"-"`
+}
+
+// Available checks the activation window of the link at the given time.
+func (l *Link) Available(now time.Time) error {
+	if !l.ActivatesAt.IsZero() && now.Before(l.ActivatesAt) {
+		return model.ErrShortNotActive
+	}
+	if !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt) {
+		return model.ErrShortExpired
+	}
+
+	return nil
+}
+
+// ClicksLeft returns how many resolutions a click-limited link has left, or -1
+// when the link is not limited.
+func (l *Link) ClicksLeft() int64 {
+	if l.MaxClicks <= 0 {
+		return -1
+	}
+	if l.Clicks >= l.MaxClicks {
+		return 0
+	}
+	return l.MaxClicks - l.Clicks
+}
+
+// CheckPassword compares the given password with the stored bcrypt hash.
+func (l *Link) CheckPassword(password string) error {
+	if l.Password == "" {
+		return nil
+	}
+	if password == "" {
+		return model.ErrShortPasswordRequired
+	}
+	if bcrypt.CompareHashAndPassword([]byte(l.Password), []byte(password)) != nil {
+		return model.ErrShortPassword
+	}
+
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", model.ErrInternal
+	}
+
+	return string(b), nil
+}
+
+type linkService struct {
+	collection        *mongo.Collection
+	settingCollection *mongo.Collection
+	historyCollection *mongo.Collection
+	// stripTracking is passed to canonical.URL for destinations set after a
+	// link was created.
+	stripTracking bool
+}
+
+var LinkService *linkService
+
+func NewLinkService(database *mongo.Database, stripTracking bool) {
+	s := &linkService{
+		collection:        database.Collection("link"),
+		settingCollection: database.Collection("linkSetting"),
+		historyCollection: database.Collection("linkHistory"),
+		stripTracking:     stripTracking,
+	}
+
+	// a code belongs to one link, the copies of shared legacy codes are told
+	// apart by their owner
+	_, err := s.collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "shortUrl", Value: 1}, {Key: "shared", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	_, err = s.historyCollection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "linkId", Value: 1}, {Key: "version", Value: 1}},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	LinkService = s
+}
+
+// primary matches the link a code resolves to.
+func primary(shortUrl string) bson.M {
+	return bson.M{"shortUrl": shortUrl, "shared": nil}
+}
+
+// CreateLink stores the extended settings of a new short code. info.Password
+// is expected in plain text and is hashed before it is saved. A code that
+// already has a link fails with model.ErrAliasTaken, existing links are never
+// overwritten.
+func (s *linkService) CreateLink(ctx context.Context, info *Link) (*Link, error) {
+	password, err := hashPassword(info.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	data := &Link{
+		ShortUrl:    info.ShortUrl,
+		Domain:      info.Domain,
+		UserId:      info.UserId,
+		LeadUrl:     info.LeadUrl,
+		Tags:        info.Tags,
+		Folder:      info.Folder,
+		Status:      StatusActive,
+		Version:     1,
+		Password:    password,
+		MaxClicks:   info.MaxClicks,
+		ActivatesAt: info.ActivatesAt,
+		ExpiresAt:   info.ExpiresAt,
+		Rules:       info.Rules,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	result, err := s.collection.InsertOne(ctx, data)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, model.ErrAliasTaken
+		}
+		return nil, model.ErrInternal
+	}
+	data.Id = result.InsertedID.(primitive.ObjectID)
+
+	return data, nil
+}
+
+// DeleteLink removes a link CreateLink has just added, for when the rest of
+// the creation failed.
+func (s *linkService) DeleteLink(ctx context.Context, id primitive.ObjectID) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+// GetLink returns the link a code resolves to.
+func (s *linkService) GetLink(ctx context.Context, shortUrl string) (*Link, error) {
+	data := &Link{}
+	err := s.collection.FindOne(ctx, primary(shortUrl)).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// GetUserLink is GetLink limited to links owned by objectId.
+func (s *linkService) GetUserLink(ctx context.Context, objectId, shortUrl string) (*Link, error) {
+	data := &Link{}
+	err := s.collection.FindOne(ctx, bson.M{"shortUrl": shortUrl, "userId": objectId}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *linkService) UpdateLinkStatus(ctx context.Context, objectId, shortUrl, status string) (*Link, error) {
+	filter := bson.M{"shortUrl": shortUrl, "userId": objectId}
+	update := bson.M{"$set": bson.M{"status": status, "updateTime": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Link{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *linkService) UpdateLinkSchedule(ctx context.Context, objectId, shortUrl string, activatesAt, expiresAt time.Time) (*Link, error) {
+	set := bson.M{"updateTime": time.Now()}
+	if !activatesAt.IsZero() {
+		set["activatesAt"] = activatesAt
+	}
+	if !expiresAt.IsZero() {
+		set["expiresAt"] = expiresAt
+	}
+
+	filter := primary(shortUrl)
+	filter["userId"] = objectId
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Link{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, s.missing(ctx, objectId, shortUrl)
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// UpdateLinkPassword replaces the password of a link, "none" removes it.
+func (s *linkService) UpdateLinkPassword(ctx context.Context, objectId, shortUrl, password string) (*Link, error) {
+	if password == "none" {
+		password = ""
+	}
+	password, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := primary(shortUrl)
+	filter["userId"] = objectId
+	update := bson.M{"$set": bson.M{"password": password, "updateTime": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Link{}
+	err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, s.missing(ctx, objectId, shortUrl)
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// ConsumeLinkClick counts one resolution of a click-limited link. The limit is
+// part of the update filter, so concurrent requests can never push the count
+// past maxClicks.
+func (s *linkService) ConsumeLinkClick(ctx context.Context, shortUrl string) (*Link, error) {
+	filter := primary(shortUrl)
+	filter["maxClicks"] = bson.M{"$gt": 0}
+	filter["$expr"] = bson.M{"$lt": bson.A{"$clicks", "$maxClicks"}}
+	update := bson.M{"$inc": bson.M{"clicks": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Link{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortExhausted
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// PurgeLink permanently drops the settings and history of a link. A bare
+// record with StatusPurge stays behind so that the code is never reused for
+// another destination. The copy of a shared legacy code is just removed, and
+// a code without a link of objectId is left alone. It reports whether the code
+// was the link of objectId, only then are its clicks theirs alone.
+func (s *linkService) PurgeLink(ctx context.Context, objectId, shortUrl string) (bool, error) {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"shortUrl": shortUrl, "shared": objectId})
+	if err != nil {
+		return false, model.ErrInternal
+	}
+	if result.DeletedCount > 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	tombstone := &Link{
+		ShortUrl:   shortUrl,
+		UserId:     objectId,
+		Status:     StatusPurge,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	filter := primary(shortUrl)
+	filter["userId"] = objectId
+	opts := options.FindOneAndReplace().SetReturnDocument(options.Before)
+
+	before := &Link{}
+	err = s.collection.FindOneAndReplace(ctx, filter, tombstone, opts).Decode(before)
+	if err != nil {
+		// a legacy code without a link still resolves for its other owners
+		if err == mongo.ErrNoDocuments {
+			return false, nil
+		}
+		return false, model.ErrInternal
+	}
+
+	_, err = s.historyCollection.DeleteMany(ctx, bson.M{"linkId": before.Id})
+	if err != nil {
+		return false, model.ErrInternal
+	}
+
+	return true, nil
+}
+
+// ClaimLink hands an anonymous link over to objectId.
+func (s *linkService) ClaimLink(ctx context.Context, objectId, shortUrl string) (*Link, error) {
+	filter := bson.M{"shortUrl": shortUrl, "userId": ""}
+	update := bson.M{"$set": bson.M{"userId": objectId, "updateTime": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Link{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// HasDomainLinks reports whether any link that is not purged is served from
+// the custom domain host.
+func (s *linkService) HasDomainLinks(ctx context.Context, host string) (bool, error) {
+	filter := bson.M{"domain": host, "status": bson.M{"$ne": StatusPurge}}
+	n, err := s.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, model.ErrInternal
+	}
+
+	return n > 0, nil
+}
+
+// FindDuplicateLink returns the active link of objectId on domain that leads
+// to leadUrl and has no password, click limit, schedule or rules, since a
+// link with any of those is not the plain link a repeated shortening asks for.
+func (s *linkService) FindDuplicateLink(ctx context.Context, objectId, domain, leadUrl string) (*Link, error) {
+	filter := bson.M{
+		"userId":      objectId,
+		"shared":      nil,
+		"leadUrl":     leadUrl,
+		"status":      StatusActive,
+		"password":    bson.M{"$in": bson.A{"", nil}},
+		"maxClicks":   bson.M{"$in": bson.A{0, nil}},
+		"activatesAt": bson.M{"$exists": false},
+		"expiresAt":   bson.M{"$exists": false},
+		"rules.0":     bson.M{"$exists": false},
+	}
+	if domain == "" {
+		filter["domain"] = bson.M{"$in": bson.A{"", nil}}
+	} else {
+		filter["domain"] = domain
+	}
+	opts := options.FindOne().SetSort(bson.M{"createTime": 1})
+
+	data := &Link{}
+	err := s.collection.FindOne(ctx, filter, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// missing tells why a change to the destination or settings of a link found
+// no link: the user only has a copy of a shared legacy code, or no link at all.
+func (s *linkService) missing(ctx context.Context, objectId, shortUrl string) error {
+	err := s.collection.FindOne(ctx, bson.M{"shortUrl": shortUrl, "shared": objectId}).Err()
+	if err == nil {
+		return model.ErrShortShared
+	}
+	if err != mongo.ErrNoDocuments {
+		return model.ErrInternal
+	}
+
+	return model.ErrShortNotFound
+}
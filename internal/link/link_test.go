@@ -0,0 +1,83 @@
+package link
+
+import (
+	"privaTutle/model"
+	"testing"
+	"time"
+)
+
+func TestAvailable(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		activatesAt time.Time
+		expiresAt   time.Time
+		want        error
+	}{
+		{"no window", time.Time{}, time.Time{}, nil},
+		{"active", now.Add(-time.Hour), now.Add(time.Hour), nil},
+		{"activates now", now, time.Time{}, nil},
+		{"not active yet", now.Add(time.Second), time.Time{}, model.ErrShortNotActive},
+		{"expires later", time.Time{}, now.Add(time.Second), nil},
+		{"expires now", time.Time{}, now, model.ErrShortExpired},
+		{"expired", time.Time{}, now.Add(-time.Hour), model.ErrShortExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Link{ActivatesAt: tt.activatesAt, ExpiresAt: tt.expiresAt}
+			if err := l.Available(now); err != tt.want {
+				t.Errorf("Available() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClicksLeft(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxClicks int64
+		clicks    int64
+		want      int64
+	}{
+		{"unlimited", 0, 10, -1},
+		{"unused", 3, 0, 3},
+		{"partly used", 3, 2, 1},
+		{"used up", 3, 3, 0},
+		{"over the limit", 3, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Link{MaxClicks: tt.maxClicks, Clicks: tt.clicks}
+			if got := l.ClicksLeft(); got != tt.want {
+				t.Errorf("ClicksLeft() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		stored   string
+		password string
+		want     error
+	}{
+		{"no password", "", "", nil},
+		{"no password ignores input", "", "anything", nil},
+		{"missing", hash, "", model.ErrShortPasswordRequired},
+		{"wrong", hash, "guess", model.ErrShortPassword},
+		{"right", hash, "secret", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Link{Password: tt.stored}
+			if err := l.CheckPassword(tt.password); err != tt.want {
+				t.Errorf("CheckPassword(%q) = %v, want %v", tt.password, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package link
+
+import (
+	"context"
+	"privaTutle/internal/canonical"
+	"privaTutle/internal/pagination"
+	"privaTutle/model"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	FilterActive    = "active"
+	FilterScheduled = "scheduled"
+	FilterExpired   = "expired"
+	FilterExhausted = "exhausted"
+	FilterDelete    = "delete"
+)
+
+var sortFields = map[string]bson.D{
+	"createTime":  {{Key: "createTime", Value: 1}, {Key: "_id", Value: 1}},
+	"-createTime": {{Key: "createTime", Value: -1}, {Key: "_id", Value: -1}},
+	"name":        {{Key: "name", Value: 1}, {Key: "_id", Value: 1}},
+	"-name":       {{Key: "name", Value: -1}, {Key: "_id", Value: -1}},
+}
+
+// ListFilter narrows ListUserLinks, zero values are ignored.
+type ListFilter struct {
+	Tag    string
+	Folder string
+	// Query matches a substring of the name, code or destination.
+	Query  string
+	Status string
+	// Health is one of the link health states or HealthUnchecked.
+	Health string
+	From   time.Time
+	To     time.Time
+	// Sort is one of createTime, name, optionally prefixed with - for
+	// descending order. Defaults to -createTime.
+	Sort string
+}
+
+func (f *ListFilter) bson(objectId string, now time.Time) bson.M {
+	filter := bson.M{"userId": objectId}
+	if f.Tag != "" {
+		filter["tags"] = f.Tag
+	}
+	if f.Folder != "" {
+		filter["folder"] = f.Folder
+	}
+	if f.Query != "" {
+		pattern := containsPattern(f.Query)
+		filter["$or"] = bson.A{
+			bson.M{"name": pattern},
+			bson.M{"shortUrl": pattern},
+			bson.M{"leadUrl": pattern},
+		}
+	}
+
+	switch f.Health {
+	case "":
+	case HealthUnchecked:
+		filter["health"] = bson.M{"$exists": false}
+	default:
+		filter["health.state"] = f.Health
+	}
+
+	createTime := bson.M{}
+	if !f.From.IsZero() {
+		createTime["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		createTime["$lt"] = f.To
+	}
+	if len(createTime) > 0 {
+		filter["createTime"] = createTime
+	}
+
+	switch f.Status {
+	case FilterActive:
+		filter["status"] = StatusActive
+		filter["$and"] = available(now)
+	case FilterScheduled:
+		filter["status"] = StatusActive
+		filter["activatesAt"] = bson.M{"$gt": now}
+	case FilterExpired:
+		filter["status"] = StatusActive
+		filter["expiresAt"] = bson.M{"$lte": now}
+	case FilterExhausted:
+		filter["status"] = StatusActive
+		filter["maxClicks"] = bson.M{"$gt": 0}
+		filter["$expr"] = bson.M{"$gte": bson.A{"$clicks", "$maxClicks"}}
+	case FilterDelete:
+		filter["status"] = StatusDelete
+	default:
+		filter["status"] = bson.M{"$nin": bson.A{StatusDelete, StatusPurge}}
+	}
+
+	return filter
+}
+
+// available matches active links that resolve at now, it is meant for $and
+// next to a status filter.
+func available(now time.Time) bson.A {
+	return bson.A{
+		bson.M{"$or": bson.A{bson.M{"activatesAt": bson.M{"$exists": false}}, bson.M{"activatesAt": bson.M{"$lte": now}}}},
+		bson.M{"$or": bson.A{bson.M{"expiresAt": bson.M{"$exists": false}}, bson.M{"expiresAt": bson.M{"$gt": now}}}},
+		bson.M{"$or": bson.A{bson.M{"maxClicks": bson.M{"$not": bson.M{"$gt": 0}}}, bson.M{"$expr": bson.M{"$lt": bson.A{"$clicks", "$maxClicks"}}}}},
+	}
+}
+
+func containsPattern(query string) bson.M {
+	return bson.M{"$regex": regexp.QuoteMeta(query), "$options": "i"}
+}
+
+func (s *linkService) ListUserLinks(ctx context.Context, objectId string, f *ListFilter, page, limit int64) ([]*Link, int64, error) {
+	filter := f.bson(objectId, time.Now())
+
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, model.ErrInternal
+	}
+
+	sort, ok := sortFields[f.Sort]
+	if !ok {
+		sort = sortFields["-createTime"]
+	}
+	opts := options.Find().SetSort(sort).SetSkip((page - 1) * limit).SetLimit(limit)
+
+	data, err := s.find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return data, total, nil
+}
+
+type linkPosition struct {
+	Sort string             `bson:"sort"`
+	Key  interface{}        `bson:"key"`
+	Id   primitive.ObjectID `bson:"id"`
+}
+
+// ListUserLinksAfter is ListUserLinks with keyset pagination. Links are read
+// after the position in cursor instead of skipping pages, so links added or
+// removed between requests do not shift the result. It returns the cursor of
+// the next page, or "" when there is none.
+func (s *linkService) ListUserLinksAfter(ctx context.Context, objectId string, f *ListFilter, cursor string, limit int64) ([]*Link, string, error) {
+	sortKey := f.Sort
+	if _, ok := sortFields[sortKey]; !ok {
+		sortKey = "-createTime"
+	}
+	sort := sortFields[sortKey]
+	field := sort[0].Key
+	op := "$gt"
+	if sort[0].Value == -1 {
+		op = "$lt"
+	}
+
+	filter := f.bson(objectId, time.Now())
+	if cursor != "" {
+		position := &linkPosition{}
+		err := pagination.Decode(cursor, position)
+		if err != nil {
+			return nil, "", err
+		}
+		if position.Sort != sortKey {
+			return nil, "", model.ErrCursor
+		}
+
+		filter = bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+			bson.M{field: bson.M{op: position.Key}},
+			bson.M{field: position.Key, "_id": bson.M{op: position.Id}},
+		}}}}
+	}
+	// the extra link only tells whether there is a next page
+	opts := options.Find().SetSort(sort).SetLimit(limit + 1)
+
+	data, err := s.find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) <= limit {
+		return data, "", nil
+	}
+
+	data = data[:limit]
+	last := data[limit-1]
+	var key interface{} = last.CreateTime
+	if field == "name" {
+		key = last.Name
+	}
+	next, err := pagination.Encode(&linkPosition{Sort: sortKey, Key: key, Id: last.Id})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, next, nil
+}
+
+func (s *linkService) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*Link, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	data := []*Link{}
+	if err = cursor.All(ctx, &data); err != nil {
+		return nil, model.ErrInternal
+	}
+	for _, d := range data {
+		d.Remaining = d.ClicksLeft()
+	}
+
+	return data, nil
+}
+
+// ImportLinks adds the legacy shorts of owners that do not track their code
+// yet, existing links are left untouched. Legacy hash codes were shared by
+// everyone shortening the same destination, so every owner gets a read-only
+// copy and the code keeps resolving through the short service, no owner can
+// change it for the others. Name, Status and CreateTime are kept as they are
+// given, a missing CreateTime becomes now. Destinations are stored in their
+// canonical form where they have one.
+func (s *linkService) ImportLinks(ctx context.Context, links []*Link) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, 0, len(links))
+	for _, l := range links {
+		models = append(models, s.importModel(l, now))
+	}
+
+	_, err := s.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+// importModel upserts the shared copy of l by owner and code.
+func (s *linkService) importModel(l *Link, now time.Time) mongo.WriteModel {
+	createTime := l.CreateTime
+	if createTime.IsZero() {
+		createTime = now
+	}
+	// legacy destinations were not validated, those that do not parse are
+	// kept as they are
+	leadUrl, err := canonical.URL(l.LeadUrl, s.stripTracking)
+	if err != nil {
+		leadUrl = l.LeadUrl
+	}
+	insert := bson.M{
+		"leadUrl":    leadUrl,
+		"name":       l.Name,
+		"status":     l.Status,
+		"version":    1,
+		"clicks":     0,
+		"shared":     l.UserId,
+		"createTime": createTime,
+		"updateTime": now,
+	}
+
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.M{"shortUrl": l.ShortUrl, "userId": l.UserId}).
+		SetUpdate(bson.M{"$setOnInsert": insert}).
+		SetUpsert(true)
+}
+
+func (s *linkService) UpdateLinkName(ctx context.Context, objectId, shortUrl, name string) (*Link, error) {
+	return s.updateLink(ctx, objectId, shortUrl, bson.M{"name": name})
+}
+
+// UpdateLinkLabels sets the tags and folder of a link. nil tags leave the tags
+// as they are, an empty folder leaves the folder and "none" clears it.
+func (s *linkService) UpdateLinkLabels(ctx context.Context, objectId, shortUrl string, tags []string, folder string) (*Link, error) {
+	set := bson.M{}
+	if tags != nil {
+		set["tags"] = tags
+	}
+	switch folder {
+	case "":
+	case "none":
+		set["folder"] = ""
+	default:
+		set["folder"] = folder
+	}
+
+	return s.updateLink(ctx, objectId, shortUrl, set)
+}
+
+// UpdateLinkRules replaces the destination rules of a link, an empty list
+// removes them.
+func (s *linkService) UpdateLinkRules(ctx context.Context, objectId, shortUrl string, rules []Rule) (*Link, error) {
+	set := bson.M{"rules": rules, "updateTime": time.Now()}
+	filter := primary(shortUrl)
+	filter["userId"] = objectId
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Link{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, s.missing(ctx, objectId, shortUrl)
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// UpdateLinkCreateTime backdates a link, used to keep the creation time of
+// links imported from elsewhere.
+func (s *linkService) UpdateLinkCreateTime(ctx context.Context, objectId, shortUrl string, createTime time.Time) (*Link, error) {
+	return s.updateLink(ctx, objectId, shortUrl, bson.M{"createTime": createTime})
+}
+
+func (s *linkService) updateLink(ctx context.Context, objectId, shortUrl string, set bson.M) (*Link, error) {
+	set["updateTime"] = time.Now()
+	filter := bson.M{"shortUrl": shortUrl, "userId": objectId}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Link{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrShortNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
@@ -0,0 +1,46 @@
+package link
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestImportModel(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-24 * time.Hour)
+	tests := []struct {
+		name           string
+		link           Link
+		wantLeadUrl    string
+		wantCreateTime time.Time
+	}{
+		{"canonical destination", Link{ShortUrl: "abc", UserId: "u1", LeadUrl: "HTTPS://Example.com", CreateTime: created}, "https://example.com/", created},
+		{"unparsable destination", Link{ShortUrl: "abc", UserId: "u2", LeadUrl: "not a url"}, "not a url", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &linkService{}
+			m := s.importModel(&tt.link, now).(*mongo.UpdateOneModel)
+
+			filter := m.Filter.(bson.M)
+			if filter["shortUrl"] != tt.link.ShortUrl || filter["userId"] != tt.link.UserId {
+				t.Errorf("filter = %v, want the link of the owner", filter)
+			}
+			insert := m.Update.(bson.M)["$setOnInsert"].(bson.M)
+			// every importer gets a read-only copy, none becomes the owner of
+			// the shared code
+			if insert["shared"] != tt.link.UserId {
+				t.Errorf("shared = %v, want %q", insert["shared"], tt.link.UserId)
+			}
+			if insert["leadUrl"] != tt.wantLeadUrl || insert["createTime"] != tt.wantCreateTime {
+				t.Errorf("leadUrl, createTime = %v, %v, want %q, %v", insert["leadUrl"], insert["createTime"], tt.wantLeadUrl, tt.wantCreateTime)
+			}
+			if m.Upsert == nil || !*m.Upsert {
+				t.Error("import does not insert missing links")
+			}
+		})
+	}
+}
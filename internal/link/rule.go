@@ -0,0 +1,143 @@
+package link
+
+import (
+	"math/rand"
+	"privaTutle/internal/analytics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule sends visitors that match all of its conditions to one of its
+// variants. Empty conditions match every visitor.
+type Rule struct {
+	// Platforms are operating system families as returned by
+	// analytics.Platform.
+	Platforms []string `bson:"platforms,omitempty" json:"platforms"`
+	// Languages match the preferred language of the visitor, "zh" matches
+	// zh-TW as well.
+	Languages []string  `bson:"languages,omitempty" json:"languages"`
+	Variants  []Variant `bson:"variants" json:"variants"`
+}
+
+// Variant is a weighted destination of a rule. Name is what the click is
+// recorded under.
+type Variant struct {
+	Name    string `bson:"name" json:"name"`
+	LeadUrl string `bson:"leadUrl" json:"leadUrl"`
+	// Weight is relative to the other variants of the rule, 0 counts as 1.
+	Weight int `bson:"weight" json:"weight"`
+}
+
+// Visitor is what rules are matched against.
+type Visitor struct {
+	Platform string
+	Language string
+}
+
+// NewVisitor reads a visitor from its User-Agent and Accept-Language headers.
+func NewVisitor(userAgent, acceptLanguage string) *Visitor {
+	return &Visitor{
+		Platform: analytics.Platform(userAgent),
+		Language: preferredLanguage(acceptLanguage),
+	}
+}
+
+// preferredLanguage returns the lowercased tag with the highest quality of an
+// Accept-Language header, the first one wins a tie.
+func preferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+
+	return best
+}
+
+func (r *Rule) match(v *Visitor) bool {
+	if len(r.Platforms) > 0 && !contains(r.Platforms, v.Platform) {
+		return false
+	}
+	if len(r.Languages) == 0 {
+		return true
+	}
+	for _, language := range r.Languages {
+		language = strings.ToLower(language)
+		if v.Language == language || strings.HasPrefix(v.Language, language+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	randMu sync.Mutex
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func (r *Rule) pick() Variant {
+	total := 0
+	for _, variant := range r.Variants {
+		total += weight(variant)
+	}
+
+	randMu.Lock()
+	n := random.Intn(total)
+	randMu.Unlock()
+
+	for _, variant := range r.Variants {
+		if n -= weight(variant); n < 0 {
+			return variant
+		}
+	}
+	return r.Variants[len(r.Variants)-1]
+}
+
+func weight(v Variant) int {
+	if v.Weight <= 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// Destination picks where a visitor is sent: a variant of the first rule the
+// visitor matches, or LeadUrl with an empty variant when no rule does.
+func (l *Link) Destination(v *Visitor) (leadUrl, variant string) {
+	for i := range l.Rules {
+		rule := &l.Rules[i]
+		if len(rule.Variants) == 0 || !rule.match(v) {
+			continue
+		}
+		picked := rule.pick()
+		return picked.LeadUrl, picked.Name
+	}
+
+	return l.LeadUrl, ""
+}
@@ -0,0 +1,108 @@
+package link
+
+import (
+	"privaTutle/internal/analytics"
+	"testing"
+)
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"zh-TW", "zh-tw"},
+		{"en-US,en;q=0.9,zh-TW;q=0.8", "en-us"},
+		{"fr;q=0.5, de;q=0.9", "de"},
+		{"*, ja;q=0.3", "ja"},
+		{"ko;q=0.7, es;q=0.7", "ko"},
+		{"it;q=bad", "it"},
+	}
+	for _, tt := range tests {
+		if got := preferredLanguage(tt.header); got != tt.want {
+			t.Errorf("preferredLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		visitor Visitor
+		want    bool
+	}{
+		{"no conditions", Rule{}, Visitor{Platform: analytics.PlatformLinux}, true},
+		{"platform", Rule{Platforms: []string{"ios", "android"}}, Visitor{Platform: analytics.PlatformIOS}, true},
+		{"other platform", Rule{Platforms: []string{"ios"}}, Visitor{Platform: analytics.PlatformAndroid}, false},
+		{"language", Rule{Languages: []string{"zh-TW"}}, Visitor{Language: "zh-tw"}, true},
+		{"language prefix", Rule{Languages: []string{"zh"}}, Visitor{Language: "zh-tw"}, true},
+		{"no partial prefix", Rule{Languages: []string{"z"}}, Visitor{Language: "zh-tw"}, false},
+		{"more specific language", Rule{Languages: []string{"zh-TW"}}, Visitor{Language: "zh"}, false},
+		{"no language", Rule{Languages: []string{"en"}}, Visitor{}, false},
+		{"both", Rule{Platforms: []string{"ios"}, Languages: []string{"ja"}}, Visitor{Platform: analytics.PlatformIOS, Language: "ja-jp"}, true},
+		{"both, one fails", Rule{Platforms: []string{"ios"}, Languages: []string{"ja"}}, Visitor{Platform: analytics.PlatformMac, Language: "ja-jp"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.match(&tt.visitor); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVisitor(t *testing.T) {
+	v := NewVisitor("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", "ja-JP,ja;q=0.9")
+	if v.Platform != analytics.PlatformIOS || v.Language != "ja-jp" {
+		t.Errorf("NewVisitor() = %+v", v)
+	}
+}
+
+func TestDestination(t *testing.T) {
+	l := &Link{
+		LeadUrl: "https://example.com/",
+		Rules: []Rule{
+			{Platforms: []string{"ios"}, Variants: []Variant{{Name: "app-store", LeadUrl: "https://apps.apple.com/app"}}},
+			{Languages: []string{"zh"}, Variants: nil},
+			{Languages: []string{"zh"}, Variants: []Variant{{Name: "zh", LeadUrl: "https://example.com/zh"}}},
+		},
+	}
+	tests := []struct {
+		name        string
+		visitor     Visitor
+		wantUrl     string
+		wantVariant string
+	}{
+		{"first matching rule", Visitor{Platform: analytics.PlatformIOS, Language: "zh-tw"}, "https://apps.apple.com/app", "app-store"},
+		{"rule without variants is skipped", Visitor{Platform: analytics.PlatformAndroid, Language: "zh-tw"}, "https://example.com/zh", "zh"},
+		{"no rule matches", Visitor{Platform: analytics.PlatformAndroid, Language: "en"}, "https://example.com/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leadUrl, variant := l.Destination(&tt.visitor)
+			if leadUrl != tt.wantUrl || variant != tt.wantVariant {
+				t.Errorf("Destination() = %q, %q, want %q, %q", leadUrl, variant, tt.wantUrl, tt.wantVariant)
+			}
+		})
+	}
+}
+
+func TestPickWeights(t *testing.T) {
+	rule := Rule{Variants: []Variant{
+		{Name: "a", Weight: 3},
+		{Name: "b", Weight: 1},
+		{Name: "never", Weight: 0},
+	}}
+	counts := map[string]int{}
+	for i := 0; i < 5000; i++ {
+		counts[rule.pick().Name]++
+	}
+	// weight 0 counts as 1, so the split is 3:1:1
+	if counts["a"] < 2600 || counts["a"] > 3400 {
+		t.Errorf("a picked %d of 5000 times, want about 3000", counts["a"])
+	}
+	if counts["b"] < 700 || counts["b"] > 1300 || counts["never"] < 700 || counts["never"] > 1300 {
+		t.Errorf("b and the weightless variant picked %d and %d times, want about 1000", counts["b"], counts["never"])
+	}
+}
@@ -0,0 +1,58 @@
+package link
+
+import (
+	"context"
+	"privaTutle/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Setting keeps the per-user defaults applied to links created from the LINE bot.
+type Setting struct {
+	UserId   string `bson:"userId" json:"userId"`
+	Lifetime int64  `bson:"lifetime" json:"lifetime"`
+	// Imported is set once the user's shorts from before the link collection
+	// have been copied into it.
+	Imported bool `bson:"imported" json:"-"`
+}
+
+func (s *linkService) GetLinkSetting(ctx context.Context, objectId string) (*Setting, error) {
+	data := &Setting{}
+	err := s.settingCollection.FindOne(ctx, bson.M{"userId": objectId}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &Setting{UserId: objectId}, nil
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *linkService) UpdateLinkLifetime(ctx context.Context, objectId string, lifetime int64) (*Setting, error) {
+	filter := bson.M{"userId": objectId}
+	update := bson.M{"$set": bson.M{"lifetime": lifetime}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	data := &Setting{}
+	err := s.settingCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *linkService) MarkLinksImported(ctx context.Context, objectId string) error {
+	filter := bson.M{"userId": objectId}
+	update := bson.M{"$set": bson.M{"imported": true}}
+
+	_, err := s.settingCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package mediastore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"privaTutle/internal/blob"
+	"privaTutle/model"
+)
+
+// ETag identifies the content of data, which never changes.
+func (data *Media) ETag() string {
+	return `"` + path.Base(data.Object) + `"`
+}
+
+// OpenContent returns the content of data as a ReadSeeker for
+// http.ServeContent, read from offset on. The object is opened right away, so
+// a missing object fails with model.ErrMediaNotFound before anything is sent.
+// Reading after a seek elsewhere only reads from the new offset on, so
+// serving a range does not download the whole object.
+func (s *mediaStoreService) OpenContent(ctx context.Context, data *Media, offset int64) (io.ReadSeekCloser, error) {
+	if offset < 0 || offset >= data.Size {
+		offset = 0
+	}
+	c := &contentReader{ctx: ctx, store: s.storeOf(data), key: data.Object, size: data.Size, offset: offset}
+	// there is no range to open of empty content
+	if data.Size == 0 {
+		return c, nil
+	}
+	if err := c.open(); err != nil {
+		if err == model.ErrObjectNotFound {
+			return nil, model.ErrMediaNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return c, nil
+}
+
+type contentReader struct {
+	ctx    context.Context
+	store  blob.Store
+	key    string
+	size   int64
+	offset int64
+	reader io.ReadCloser
+	// readerOffset is where reader continues, seeks only move offset
+	readerOffset int64
+}
+
+func (c *contentReader) Read(p []byte) (int, error) {
+	if c.offset >= c.size {
+		return 0, io.EOF
+	}
+	if c.reader != nil && c.readerOffset != c.offset {
+		c.Close()
+	}
+	if c.reader == nil {
+		if err := c.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := c.reader.Read(p)
+	c.offset += int64(n)
+	c.readerOffset = c.offset
+	return n, err
+}
+
+func (c *contentReader) open() error {
+	reader, err := c.store.OpenRange(c.ctx, c.key, c.offset, -1)
+	if err != nil {
+		return err
+	}
+	c.reader = reader
+	c.readerOffset = c.offset
+	return nil
+}
+
+// Seek only moves the offset, the object is opened again by the next Read if
+// it starts elsewhere than the open reader.
+func (c *contentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.size
+	}
+	if offset < 0 {
+		return 0, errors.New("mediastore: negative offset")
+	}
+	c.offset = offset
+
+	return offset, nil
+}
+
+func (c *contentReader) Close() error {
+	if c.reader == nil {
+		return nil
+	}
+	err := c.reader.Close()
+	c.reader = nil
+	return err
+}
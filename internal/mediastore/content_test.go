@@ -0,0 +1,98 @@
+package mediastore
+
+import (
+	"context"
+	"io"
+	"privaTutle/internal/blob"
+	"privaTutle/model"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T, objects map[string]string) blob.Store {
+	store, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, content := range objects {
+		if _, err := store.Put(context.Background(), key, "text/plain", strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestOpenContent(t *testing.T) {
+	s := &mediaStoreService{
+		store:  newTestStore(t, map[string]string{"media/a": "new content"}),
+		legacy: newTestStore(t, map[string]string{"old/b": "old content"}),
+	}
+	tests := []struct {
+		name   string
+		data   *Media
+		offset int64
+		want   string
+		err    error
+	}{
+		{"store", &Media{Object: "media/a", Size: 11}, 0, "new content", nil},
+		{"offset", &Media{Object: "media/a", Size: 11}, 4, "content", nil},
+		{"offset past the end", &Media{Object: "media/a", Size: 11}, 11, "new content", nil},
+		{"legacy", &Media{Object: "old/b", Size: 11, Legacy: true}, 0, "old content", nil},
+		{"legacy object in the store", &Media{Object: "old/b", Size: 11}, 0, "", model.ErrMediaNotFound},
+		{"missing", &Media{Object: "media/gone", Size: 3}, 0, "", model.ErrMediaNotFound},
+		{"empty", &Media{Object: "media/gone"}, 0, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := s.OpenContent(context.Background(), tt.data, tt.offset)
+			if err != tt.err {
+				t.Fatalf("OpenContent() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			defer content.Close()
+			if tt.offset < tt.data.Size {
+				if _, err := content.Seek(tt.offset, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := io.ReadAll(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("content = %q, want %q", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentSeek(t *testing.T) {
+	// http.ServeContent seeks to the end for the size and back before reading
+	s := &mediaStoreService{store: newTestStore(t, map[string]string{"media/a": "0123456789"})}
+	content, err := s.OpenContent(context.Background(), &Media{Object: "media/a", Size: 10}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+
+	if size, _ := content.Seek(0, io.SeekEnd); size != 10 {
+		t.Fatalf("Seek(0, end) = %d, want 10", size)
+	}
+	content.Seek(0, io.SeekStart)
+	b := make([]byte, 3)
+	io.ReadFull(content, b)
+	if string(b) != "012" {
+		t.Errorf("first read = %q, want %q", b, "012")
+	}
+
+	content.Seek(7, io.SeekStart)
+	rest, _ := io.ReadAll(content)
+	if string(rest) != "789" {
+		t.Errorf("read after seek = %q, want %q", rest, "789")
+	}
+	if _, err := content.Seek(-1, io.SeekStart); err == nil {
+		t.Error("negative seek did not fail")
+	}
+}
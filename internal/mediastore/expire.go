@@ -0,0 +1,57 @@
+package mediastore
+
+import (
+	"context"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClaimExpired leases the longest expired media that still has content, or
+// returns nil when there is none. Other instances skip it until lease has
+// passed, so a crashed removal is retried.
+func (s *mediaStoreService) ClaimExpired(ctx context.Context, lease time.Duration) (*Media, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":     bson.M{"$in": bson.A{StatusActive, StatusDelete}},
+		"expireTime": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"leaseTime": bson.M{"$exists": false}},
+			bson.M{"leaseTime": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"leaseTime": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"expireTime": 1}).SetReturnDocument(options.After)
+
+	data := &Media{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// RemoveExpired deletes the content of claimed media and marks it
+// StatusExpired.
+func (s *mediaStoreService) RemoveExpired(ctx context.Context, data *Media) error {
+	if err := s.storeOf(data).Delete(ctx, data.Object); err != nil {
+		return err
+	}
+
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": data.Id}, bson.M{
+		"$set":   bson.M{"status": StatusExpired},
+		"$unset": bson.M{"leaseTime": ""},
+	})
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
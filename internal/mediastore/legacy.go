@@ -0,0 +1,129 @@
+package mediastore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyMedia is a record the former media service kept in the "media"
+// collection. Its content is the object Object of the legacy bucket.
+type legacyMedia struct {
+	Id       primitive.ObjectID `bson:"_id"`
+	ShortUrl string             `bson:"shortUrl"`
+	UserId   string             `bson:"userId"`
+	Name     string             `bson:"name"`
+	Type     string             `bson:"type"`
+	Object   string             `bson:"object"`
+	// Password is a bcrypt hash, or "none" for media without one.
+	Password   string    `bson:"password"`
+	Status     string    `bson:"status"`
+	ExpireTime time.Time `bson:"expireTime"`
+	CreateTime time.Time `bson:"createTime"`
+}
+
+// MigrateLegacy moves the records of the former media service over, so that
+// its media are served, listed, trashed and swept like any other while their
+// content stays in the legacy bucket. Moved records are marked, a record is
+// never moved twice, so it is safe to run on every start of every instance.
+// It returns the number of media moved.
+func (s *mediaStoreService) MigrateLegacy(ctx context.Context) (int, error) {
+	if s.legacy == nil {
+		return 0, nil
+	}
+
+	cursor, err := s.legacyCollection.Find(ctx, bson.M{"migrated": bson.M{"$ne": true}})
+	if err != nil {
+		return 0, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	moved := 0
+	for cursor.Next(ctx) {
+		old := &legacyMedia{}
+		if err = cursor.Decode(old); err != nil {
+			return moved, model.ErrInternal
+		}
+
+		now := time.Now()
+		data := fromLegacy(old)
+		// the former service kept neither size nor type, media that can still
+		// be viewed need them to be served
+		if data.Status == StatusActive && now.Before(data.ExpireTime) {
+			data.ContentType, data.Size, err = s.measureLegacy(ctx, data.Object)
+			if err == model.ErrObjectNotFound {
+				data.Status = StatusExpired
+			} else if err != nil {
+				return moved, err
+			}
+		}
+
+		_, err = s.collection.UpdateOne(ctx, bson.M{"shortUrl": data.ShortUrl}, bson.M{"$setOnInsert": data}, options.Update().SetUpsert(true))
+		if err != nil {
+			return moved, model.ErrInternal
+		}
+		_, err = s.legacyCollection.UpdateOne(ctx, bson.M{"_id": old.Id}, bson.M{"$set": bson.M{"migrated": true}})
+		if err != nil {
+			return moved, model.ErrInternal
+		}
+		moved++
+	}
+	if err = cursor.Err(); err != nil {
+		return moved, model.ErrInternal
+	}
+
+	return moved, nil
+}
+
+// fromLegacy returns the media of a record of the former media service.
+// Expired ones keep their status, the sweeper removes their content.
+func fromLegacy(old *legacyMedia) *Media {
+	data := &Media{
+		ShortUrl:   old.ShortUrl,
+		UserId:     old.UserId,
+		Name:       old.Name,
+		Type:       old.Type,
+		Object:     old.Object,
+		Password:   old.Password,
+		Status:     StatusActive,
+		ExpireTime: old.ExpireTime,
+		CreateTime: old.CreateTime,
+		Legacy:     true,
+	}
+	if data.Password == "none" {
+		data.Password = ""
+	}
+	if old.Status == StatusDelete {
+		data.Status = StatusDelete
+	}
+
+	return data
+}
+
+// measureLegacy reads the object key of the legacy bucket for its type and
+// size.
+func (s *mediaStoreService) measureLegacy(ctx context.Context, key string) (string, int64, error) {
+	reader, err := s.legacy.Open(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer reader.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", 0, model.ErrInternal
+	}
+	rest, err := io.Copy(io.Discard, reader)
+	if err != nil {
+		return "", 0, model.ErrInternal
+	}
+
+	return http.DetectContentType(head[:n]), int64(n) + rest, nil
+}
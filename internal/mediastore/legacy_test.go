@@ -0,0 +1,54 @@
+package mediastore
+
+import (
+	"context"
+	"privaTutle/model"
+	"testing"
+)
+
+func TestFromLegacy(t *testing.T) {
+	tests := []struct {
+		name         string
+		old          legacyMedia
+		wantPassword string
+		wantStatus   string
+	}{
+		{"no password", legacyMedia{Password: "none", Status: "active"}, "", StatusActive},
+		{"password", legacyMedia{Password: "$2a$10$hash", Status: "active"}, "$2a$10$hash", StatusActive},
+		{"deleted", legacyMedia{Password: "none", Status: "delete"}, "", StatusDelete},
+		{"other status", legacyMedia{Password: "none", Status: ""}, "", StatusActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.old.ShortUrl, tt.old.Object = "abc", "media/abc"
+			data := fromLegacy(&tt.old)
+			if data.Password != tt.wantPassword || data.Status != tt.wantStatus {
+				t.Errorf("fromLegacy() password %q, status %q, want %q, %q", data.Password, data.Status, tt.wantPassword, tt.wantStatus)
+			}
+			if !data.Legacy || data.ShortUrl != "abc" || data.Object != "media/abc" {
+				t.Errorf("fromLegacy() = %+v does not read the legacy object", data)
+			}
+		})
+	}
+}
+
+func TestMeasureLegacy(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + string(make([]byte, 1000))
+	s := &mediaStoreService{legacy: newTestStore(t, map[string]string{"media/png": png, "media/text": "hello"})}
+	tests := []struct {
+		key             string
+		wantContentType string
+		wantSize        int64
+		err             error
+	}{
+		{"media/png", "image/png", int64(len(png)), nil},
+		{"media/text", "text/plain; charset=utf-8", 5, nil},
+		{"media/gone", "", 0, model.ErrObjectNotFound},
+	}
+	for _, tt := range tests {
+		contentType, size, err := s.measureLegacy(context.Background(), tt.key)
+		if err != tt.err || contentType != tt.wantContentType || size != tt.wantSize {
+			t.Errorf("measureLegacy(%q) = %q, %d, %v, want %q, %d, %v", tt.key, contentType, size, err, tt.wantContentType, tt.wantSize, tt.err)
+		}
+	}
+}
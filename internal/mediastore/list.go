@@ -0,0 +1,169 @@
+package mediastore
+
+import (
+	"context"
+	"privaTutle/internal/pagination"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// userMedia matches the media objectId lists. Media in the trash are left
+// out, and so are expired media whose content the sweeper removed, whether
+// they were in the trash or not.
+func userMedia(objectId string) bson.M {
+	return bson.M{"userId": objectId, "status": StatusActive}
+}
+
+// ListUserMedia returns the media of objectId that are neither in the trash nor
+// removed, latest first.
+func (s *mediaStoreService) ListUserMedia(ctx context.Context, objectId string, page, limit int64) ([]*Media, int64, error) {
+	filter := userMedia(objectId)
+
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, model.ErrInternal
+	}
+
+	sort := bson.D{{Key: "createTime", Value: -1}, {Key: "_id", Value: -1}}
+	opts := options.Find().SetSort(sort).SetSkip((page - 1) * limit).SetLimit(limit)
+
+	data, err := s.find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return data, total, nil
+}
+
+type mediaPosition struct {
+	CreateTime time.Time          `bson:"createTime"`
+	Id         primitive.ObjectID `bson:"id"`
+}
+
+// ListUserMediaAfter is ListUserMedia with keyset pagination. Media are read
+// after the position in cursor instead of skipping pages, so media added or
+// removed between requests do not shift the result. It returns the cursor of
+// the next page, or "" when there is none.
+func (s *mediaStoreService) ListUserMediaAfter(ctx context.Context, objectId, cursor string, limit int64) ([]*Media, string, error) {
+	filter := userMedia(objectId)
+	if cursor != "" {
+		position := &mediaPosition{}
+		err := pagination.Decode(cursor, position)
+		if err != nil {
+			return nil, "", err
+		}
+
+		filter["$or"] = bson.A{
+			bson.M{"createTime": bson.M{"$lt": position.CreateTime}},
+			bson.M{"createTime": position.CreateTime, "_id": bson.M{"$lt": position.Id}},
+		}
+	}
+	sort := bson.D{{Key: "createTime", Value: -1}, {Key: "_id", Value: -1}}
+	// the extra media only tells whether there is a next page
+	opts := options.Find().SetSort(sort).SetLimit(limit + 1)
+
+	data, err := s.find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) <= limit {
+		return data, "", nil
+	}
+
+	data = data[:limit]
+	last := data[limit-1]
+	next, err := pagination.Encode(&mediaPosition{CreateTime: last.CreateTime, Id: last.Id})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, next, nil
+}
+
+func (s *mediaStoreService) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*Media, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	data := []*Media{}
+	if err = cursor.All(ctx, &data); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// UpdateMediaStatus moves media of objectId between StatusActive and
+// StatusDelete. Expired media have lost their content and cannot be changed.
+func (s *mediaStoreService) UpdateMediaStatus(ctx context.Context, objectId, shortUrl, status string) (*Media, error) {
+	return s.updateMedia(ctx, objectId, shortUrl, bson.M{"status": status})
+}
+
+func (s *mediaStoreService) UpdateMediaName(ctx context.Context, objectId, shortUrl, name string) (*Media, error) {
+	return s.updateMedia(ctx, objectId, shortUrl, bson.M{"name": name})
+}
+
+// UpdateMediaExpirationTime lets the media expire expirationTime seconds from
+// now.
+func (s *mediaStoreService) UpdateMediaExpirationTime(ctx context.Context, objectId, shortUrl string, expirationTime int64) (*Media, error) {
+	expireTime := time.Now().Add(time.Duration(expirationTime) * time.Second)
+	return s.updateMedia(ctx, objectId, shortUrl, bson.M{"expireTime": expireTime})
+}
+
+// UpdateMediaPassword replaces the password, which is hashed with
+// HashPassword.
+func (s *mediaStoreService) UpdateMediaPassword(ctx context.Context, objectId, shortUrl, password string) (*Media, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateMedia(ctx, objectId, shortUrl, bson.M{"password": hash})
+}
+
+// PurgeMedia removes the content and the record of media of objectId. Media
+// that are already gone are not an error, so a failed purge can be retried.
+func (s *mediaStoreService) PurgeMedia(ctx context.Context, objectId, shortUrl string) error {
+	data := &Media{}
+	err := s.collection.FindOne(ctx, bson.M{"shortUrl": shortUrl, "userId": objectId}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return model.ErrInternal
+	}
+
+	// the record goes last, it is what finds the content
+	if err = s.storeOf(data).Delete(ctx, data.Object); err != nil {
+		return err
+	}
+	_, err = s.collection.DeleteOne(ctx, bson.M{"_id": data.Id})
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+func (s *mediaStoreService) updateMedia(ctx context.Context, objectId, shortUrl string, set bson.M) (*Media, error) {
+	filter := bson.M{"shortUrl": shortUrl, "userId": objectId, "status": bson.M{"$ne": StatusExpired}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Media{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrMediaNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
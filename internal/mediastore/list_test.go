@@ -0,0 +1,11 @@
+package mediastore
+
+import "testing"
+
+func TestUserMedia(t *testing.T) {
+	// the sweeper marks trashed media expired, they must not be listed again
+	filter := userMedia("user")
+	if filter["userId"] != "user" || filter["status"] != StatusActive {
+		t.Errorf("userMedia() = %v, want the active media of user", filter)
+	}
+}
@@ -0,0 +1,308 @@
+package mediastore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"math/big"
+	"privaTutle/internal/blob"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	StatusActive = "active"
+	StatusDelete = "delete"
+	// StatusExpired marks media whose content was removed after it expired.
+	StatusExpired = "expired"
+)
+
+// Media is an uploaded image or video whose content is kept in our own blob
+// store.
+type Media struct {
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ShortUrl string             `bson:"shortUrl" json:"shortUrl"`
+	UserId   string             `bson:"userId" json:"userId"`
+	Name     string             `bson:"name" json:"name"`
+	Type     string             `bson:"type" json:"type"`
+	// Object is the key of the content in the blob store.
+	Object      string    `bson:"object" json:"-"`
+	ContentType string    `bson:"contentType" json:"contentType"`
+	Size        int64     `bson:"size" json:"size"`
+	Password    string    `bson:"password,omitempty" json:"-"`
+	Status      string    `bson:"status" json:"status"`
+	ExpireTime  time.Time `bson:"expireTime" json:"expireTime"`
+	CreateTime  time.Time `bson:"createTime" json:"createTime"`
+	// LeaseTime is set while an instance is removing the expired content.
+	LeaseTime time.Time `bson:"leaseTime,omitempty" json:"-"`
+	// Legacy is set on media of the former media service, their content
+	// stays in its bucket.
+	Legacy bool `bson:"legacy,omitempty" json:"-"`
+}
+
+type Config struct {
+	// MaxSize is the largest media in bytes.
+	MaxSize int64
+	// URLTTL is the longest lifetime of a download url.
+	URLTTL time.Duration
+	// SigningKey signs the urls of our own download route. All instances
+	// need the same key. It is required unless the store signs urls itself,
+	// then a random key is used for urls the store fails to sign.
+	SigningKey string
+	// BaseURL is the address of this server, download urls of stores that
+	// cannot sign urls point to it.
+	BaseURL string
+}
+
+type mediaStoreService struct {
+	collection       *mongo.Collection
+	store            blob.Store
+	legacyCollection *mongo.Collection
+	legacy           blob.Store
+	cnf              Config
+}
+
+var MediaStoreService *mediaStoreService
+
+// NewMediaStoreService keeps the content of media and upload parts in store.
+// legacy is the bucket of the former media service, the content of its media
+// is read and removed there. It is nil when there is no such bucket.
+func NewMediaStoreService(database *mongo.Database, store blob.Store, legacy blob.Store, cnf Config) {
+	if cnf.SigningKey == "" {
+		// without a key of our own, urls of our download route would only
+		// work on the instance that made them
+		if _, ok := store.(blob.Signer); !ok {
+			panic("mediastore: media.signingKey is required for a store that cannot sign urls")
+		}
+		log.Println("mediastore: no signing key configured, urls the store fails to sign only work on this instance until it restarts")
+		key, err := randomHex(32)
+		if err != nil {
+			panic(err)
+		}
+		cnf.SigningKey = key
+	}
+
+	MediaStoreService = &mediaStoreService{
+		collection:       database.Collection("mediaStore"),
+		store:            store,
+		legacyCollection: database.Collection("media"),
+		legacy:           legacy,
+		cnf:              cnf,
+	}
+}
+
+// MaxSize returns the largest media in bytes.
+func (s *mediaStoreService) MaxSize() int64 {
+	return s.cnf.MaxSize
+}
+
+// Put streams r into a new object under prefix and returns its key and size.
+// Content beyond max bytes fails with model.ErrMediaTooLarge and leaves no
+// object behind.
+func (s *mediaStoreService) Put(ctx context.Context, prefix, contentType string, r io.Reader, max int64) (string, int64, error) {
+	name, err := randomHex(16)
+	if err != nil {
+		return "", 0, err
+	}
+	key := prefix + name
+
+	size, err := s.store.Put(ctx, key, contentType, &limitReader{r: r, n: max})
+	if err != nil {
+		if err == model.ErrMediaTooLarge {
+			return "", 0, err
+		}
+		return "", 0, model.ErrInternal
+	}
+
+	return key, size, nil
+}
+
+// limitReader fails with model.ErrMediaTooLarge once r has more than n bytes
+// left, so that the store drops the object.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// one more byte tells a full read from a too large one
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, model.ErrMediaTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// storeOf returns the store that keeps the content of data.
+func (s *mediaStoreService) storeOf(data *Media) blob.Store {
+	if data.Legacy && s.legacy != nil {
+		return s.legacy
+	}
+	return s.store
+}
+
+// Open returns a reader for the object key.
+func (s *mediaStoreService) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := s.store.Open(ctx, key)
+	if err != nil {
+		if err == model.ErrObjectNotFound {
+			return nil, model.ErrMediaNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return reader, nil
+}
+
+// DeleteObject removes the object key, an object that is already gone is
+// not an error.
+func (s *mediaStoreService) DeleteObject(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// HashPassword returns the hash CreateMedia expects as password, "" stays
+// without a password.
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", model.ErrInternal
+	}
+
+	return string(hash), nil
+}
+
+// CreateMedia stores the record of content already written with Put under a
+// new short code. info.Password is hashed with HashPassword. expirationTime is
+// in seconds.
+func (s *mediaStoreService) CreateMedia(ctx context.Context, info *Media, expirationTime int64) (*Media, error) {
+	now := time.Now()
+	data := &Media{
+		UserId:      info.UserId,
+		Name:        info.Name,
+		Type:        info.Type,
+		Object:      info.Object,
+		ContentType: info.ContentType,
+		Size:        info.Size,
+		Password:    info.Password,
+		Status:      StatusActive,
+		ExpireTime:  now.Add(time.Duration(expirationTime) * time.Second),
+		CreateTime:  now,
+	}
+	// codes are long enough that a collision is retried at most a few times
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := randomCode(codeLength)
+		if err != nil {
+			return nil, err
+		}
+		data.ShortUrl = code
+		result, err := s.collection.UpdateOne(ctx, bson.M{"shortUrl": data.ShortUrl}, bson.M{"$setOnInsert": data}, options.Update().SetUpsert(true))
+		if err != nil {
+			return nil, model.ErrInternal
+		}
+		if result.UpsertedID != nil {
+			data.Id = result.UpsertedID.(primitive.ObjectID)
+			return data, nil
+		}
+	}
+
+	return nil, model.ErrInternal
+}
+
+func (s *mediaStoreService) GetMedia(ctx context.Context, shortUrl string) (*Media, error) {
+	data := &Media{}
+	err := s.collection.FindOne(ctx, bson.M{"shortUrl": shortUrl}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrMediaNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// TranslateMedia returns the media of shortUrl once the password matches.
+// Owners do not need the password.
+func (s *mediaStoreService) TranslateMedia(ctx context.Context, shortUrl, password, objectId string) (*Media, error) {
+	data, err := s.GetMedia(ctx, shortUrl)
+	if err != nil {
+		return nil, err
+	}
+	if data.Status != StatusActive {
+		return nil, model.ErrMediaNotFound
+	}
+	if !time.Now().Before(data.ExpireTime) {
+		return nil, model.ErrMediaExpired
+	}
+	if data.Password != "" && (objectId == "" || objectId != data.UserId) {
+		if bcrypt.CompareHashAndPassword([]byte(data.Password), []byte(password)) != nil {
+			return nil, model.ErrMediaPassword
+		}
+	}
+
+	return data, nil
+}
+
+// ClaimMedia hands anonymous media over to objectId.
+func (s *mediaStoreService) ClaimMedia(ctx context.Context, objectId, shortUrl string) (*Media, error) {
+	filter := bson.M{"shortUrl": shortUrl, "userId": ""}
+	update := bson.M{"$set": bson.M{"userId": objectId}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Media{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrMediaNotFound
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+const (
+	codeLength   = 10
+	codeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+func randomCode(length int) (string, error) {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", model.ErrInternal
+		}
+		b[i] = codeAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", model.ErrInternal
+	}
+	return hex.EncodeToString(b), nil
+}
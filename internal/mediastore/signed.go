@@ -0,0 +1,66 @@
+package mediastore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/url"
+	"privaTutle/internal/blob"
+	"privaTutle/model"
+	"strconv"
+	"time"
+)
+
+// SignedURL returns a url that reads the content of data until the returned
+// time, which is never later than the expiration of the media. Stores that
+// can sign urls are read directly, otherwise the url points to our own
+// download route.
+func (s *mediaStoreService) SignedURL(data *Media) (string, time.Time, error) {
+	expires := time.Now().Add(s.cnf.URLTTL)
+	if data.ExpireTime.Before(expires) {
+		expires = data.ExpireTime
+	}
+	// signatures only carry whole seconds
+	expires = expires.Truncate(time.Second)
+	if !time.Now().Before(expires) {
+		return "", time.Time{}, model.ErrMediaExpired
+	}
+
+	if signer, ok := s.storeOf(data).(blob.Signer); ok {
+		signed, err := signer.SignedURL(data.Object, expires)
+		if err == nil {
+			return signed, expires, nil
+		}
+		// e.g. credentials without a private key, our own route still works
+		log.Println("mediastore: sign url:", err)
+	}
+
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {s.signature(data.ShortUrl, expires.Unix())},
+	}
+	return s.cnf.BaseURL + "/api/media/" + data.ShortUrl + "/download?" + query.Encode(), expires, nil
+}
+
+// VerifySignature checks a download url made by SignedURL.
+func (s *mediaStoreService) VerifySignature(shortUrl, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return model.ErrParameter
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(shortUrl, unix))) {
+		return model.ErrMediaSignature
+	}
+	if !time.Now().Before(time.Unix(unix, 0)) {
+		return model.ErrMediaExpired
+	}
+
+	return nil
+}
+
+func (s *mediaStoreService) signature(shortUrl string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.cnf.SigningKey))
+	mac.Write([]byte(shortUrl + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
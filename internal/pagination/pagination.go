@@ -0,0 +1,33 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"privaTutle/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Encode packs a cursor position into an opaque url-safe token. bson keeps
+// times and object ids intact, so the decoded values can go straight back into
+// a query.
+func Encode(position interface{}) (string, error) {
+	b, err := bson.Marshal(position)
+	if err != nil {
+		return "", model.ErrInternal
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Decode unpacks a token made by Encode into position.
+func Decode(token string, position interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return model.ErrCursor
+	}
+	if err = bson.Unmarshal(b, position); err != nil {
+		return model.ErrCursor
+	}
+
+	return nil
+}
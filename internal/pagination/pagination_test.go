@@ -0,0 +1,73 @@
+package pagination
+
+import (
+	"privaTutle/model"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type position struct {
+	Sort string             `bson:"sort"`
+	Key  interface{}        `bson:"key"`
+	Time time.Time          `bson:"time"`
+	Id   primitive.ObjectID `bson:"id"`
+}
+
+func TestEncodeDecode(t *testing.T) {
+	id := primitive.NewObjectID()
+	now := time.Date(2024, 5, 1, 12, 30, 0, 123000000, time.UTC)
+	tests := []struct {
+		name string
+		in   position
+	}{
+		{"time key", position{Sort: "-createTime", Key: now, Time: now, Id: id}},
+		{"string key", position{Sort: "name", Key: "névé", Id: id}},
+		{"zero", position{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Encode(&tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := position{}
+			if err = Decode(token, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Sort != tt.in.Sort || out.Id != tt.in.Id || !out.Time.Equal(tt.in.Time) {
+				t.Errorf("Decode(Encode(%+v)) = %+v", tt.in, out)
+			}
+			switch key := tt.in.Key.(type) {
+			case time.Time:
+				if got, ok := out.Key.(primitive.DateTime); !ok || !got.Time().Equal(key) {
+					t.Errorf("key = %#v, want %v", out.Key, key)
+				}
+			case string:
+				if out.Key != key {
+					t.Errorf("key = %#v, want %q", out.Key, key)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "***"},
+		{"padded base64", "AAAA=="},
+		{"not bson", "aGVsbG8"},
+		{"truncated", "DAAAAAJzb3J0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Decode(tt.token, &position{}); err != model.ErrCursor {
+				t.Errorf("Decode(%q) = %v, want %v", tt.token, err, model.ErrCursor)
+			}
+		})
+	}
+}
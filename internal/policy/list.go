@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// domainList holds the entries of the list file. Each non-empty line is
+// either "deny <domain>" or "allow <domain>", lines starting with # are
+// comments. An entry also covers every subdomain of its domain. As soon as one
+// allow entry exists, only allowed domains can be shortened.
+type domainList struct {
+	modTime time.Time
+	deny    map[string]bool
+	allow   map[string]bool
+}
+
+func (l *domainList) allowed(host string) bool {
+	if l.match(l.deny, host) {
+		return false
+	}
+	if len(l.allow) > 0 {
+		return l.match(l.allow, host)
+	}
+	return true
+}
+
+func (l *domainList) match(domains map[string]bool, host string) bool {
+	for {
+		if domains[host] {
+			return true
+		}
+		i := strings.Index(host, ".")
+		if i == -1 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
+
+func loadDomainList(path string) (*domainList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &domainList{
+		modTime: stat.ModTime(),
+		deny:    map[string]bool{},
+		allow:   map[string]bool{},
+	}
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("policy: invalid entry on line %d: %q", line, text)
+		}
+		domain := hostname(fields[1])
+		switch strings.ToLower(fields[0]) {
+		case "deny":
+			list.deny[domain] = true
+		case "allow":
+			list.allow[domain] = true
+		default:
+			return nil, fmt.Errorf("policy: invalid entry on line %d: %q", line, text)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (s *policyService) reload() error {
+	list, err := loadDomainList(s.listFile)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.list = list
+	s.mu.Unlock()
+
+	return nil
+}
+
+// watch reloads the list file whenever its modification time changes. A
+// broken file is logged and the previous list stays in use.
+func (s *policyService) watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		stat, err := os.Stat(s.listFile)
+		if err != nil {
+			log.Println("policy: stat domain list:", err)
+			continue
+		}
+
+		s.mu.RLock()
+		changed := !stat.ModTime().Equal(s.list.modTime)
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err = s.reload(); err != nil {
+			log.Println("policy: reload domain list:", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package policy
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"privaTutle/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Schemes lists the url schemes that may be shortened.
+	Schemes []string
+	// BlockPrivate rejects destinations on loopback, private and link-local networks.
+	BlockPrivate bool
+	// SelfHosts are the hosts short links are served from, linking to them
+	// would only redirect back to us.
+	SelfHosts []string
+	// IsSelfHost reports hosts that are served by us besides SelfHosts, such
+	// as custom domains. Optional.
+	IsSelfHost func(ctx context.Context, host string) bool
+	// ListFile is the optional domain list, see loadDomainList for its format.
+	ListFile string
+	// ReloadInterval is how often ListFile is checked for changes.
+	ReloadInterval time.Duration
+}
+
+type policyService struct {
+	schemes      map[string]bool
+	blockPrivate bool
+	selfHosts    map[string]bool
+	isSelfHost   func(ctx context.Context, host string) bool
+	resolver     *net.Resolver
+
+	listFile string
+	mu       sync.RWMutex
+	list     *domainList
+}
+
+var PolicyService *policyService
+
+func NewPolicyService(cnf Config) {
+	s := &policyService{
+		schemes:      map[string]bool{},
+		blockPrivate: cnf.BlockPrivate,
+		selfHosts:    map[string]bool{},
+		isSelfHost:   cnf.IsSelfHost,
+		resolver:     net.DefaultResolver,
+		listFile:     cnf.ListFile,
+		list:         &domainList{},
+	}
+	for _, scheme := range cnf.Schemes {
+		s.schemes[strings.ToLower(scheme)] = true
+	}
+	for _, host := range cnf.SelfHosts {
+		if host = hostname(host); host != "" {
+			s.selfHosts[host] = true
+		}
+	}
+
+	if s.listFile != "" {
+		if err := s.reload(); err != nil {
+			panic(err)
+		}
+		go s.watch(cnf.ReloadInterval)
+	}
+
+	PolicyService = s
+}
+
+// Check tells whether rawUrl may be used as the destination of a short link.
+func (s *policyService) Check(ctx context.Context, rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return model.ErrParameter
+	}
+	if !s.schemes[strings.ToLower(u.Scheme)] {
+		return model.ErrUrlScheme
+	}
+	if u.Host == "" {
+		return model.ErrParameter
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if s.selfHosts[host] || (s.isSelfHost != nil && s.isSelfHost(ctx, host)) {
+		return model.ErrUrlLoop
+	}
+
+	s.mu.RLock()
+	list := s.list
+	s.mu.RUnlock()
+	if !list.allowed(host) {
+		return model.ErrUrlDenied
+	}
+
+	if s.blockPrivate {
+		return s.checkAddress(ctx, host)
+	}
+
+	return nil
+}
+
+// checkAddress rejects hosts that are, or resolve to, addresses outside the
+// public internet. Hosts that cannot be resolved right now are let through,
+// a failing lookup says nothing about where the link will point later.
+func (s *policyService) checkAddress(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublic(ip) {
+			return model.ErrUrlPrivate
+		}
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return model.ErrUrlPrivate
+	}
+
+	addrs, err := s.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !isPublic(addr.IP) {
+			return model.ErrUrlPrivate
+		}
+	}
+
+	return nil
+}
+
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// hostname accepts either a bare host or a url such as frontend.host.
+func hostname(s string) string {
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		s = u.Hostname()
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+}
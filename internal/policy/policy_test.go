@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"privaTutle/model"
+	"testing"
+)
+
+func newTestService(t *testing.T, list string) *policyService {
+	s := &policyService{
+		schemes:      map[string]bool{"http": true, "https": true},
+		blockPrivate: true,
+		selfHosts:    map[string]bool{"tutle.example": true, "api.tutle.example": true},
+		isSelfHost: func(ctx context.Context, host string) bool {
+			return host == "go.custom.example"
+		},
+		resolver: net.DefaultResolver,
+		list:     &domainList{},
+	}
+	if list != "" {
+		path := filepath.Join(t.TempDir(), "domains.txt")
+		if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		l, err := loadDomainList(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.list = l
+	}
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		list   string
+		rawUrl string
+		want   error
+	}{
+		{"public ip", "", "https://93.184.216.34/", nil},
+		{"scheme", "", "ftp://93.184.216.34/", model.ErrUrlScheme},
+		{"javascript", "", "javascript:alert(1)", model.ErrUrlScheme},
+		{"no host", "", "https:///path", model.ErrParameter},
+		{"unparsable", "", "https://%zz/", model.ErrParameter},
+		{"self host", "", "https://tutle.example/abc", model.ErrUrlLoop},
+		{"self host case and dot", "", "https://API.tutle.example./abc", model.ErrUrlLoop},
+		{"custom domain", "", "https://go.custom.example/abc", model.ErrUrlLoop},
+		{"loopback", "", "http://127.0.0.1:8080/", model.ErrUrlPrivate},
+		{"private", "", "http://10.1.2.3/", model.ErrUrlPrivate},
+		{"link local", "", "http://169.254.169.254/latest/meta-data", model.ErrUrlPrivate},
+		{"shared address space", "", "http://100.64.0.1/", model.ErrUrlPrivate},
+		{"ipv6 loopback", "", "http://[::1]/", model.ErrUrlPrivate},
+		{"unspecified", "", "http://0.0.0.0/", model.ErrUrlPrivate},
+		{"localhost", "", "http://localhost/", model.ErrUrlPrivate},
+		{"localhost subdomain", "", "http://app.localhost/", model.ErrUrlPrivate},
+		{"denied domain", "deny bad.example\n", "https://bad.example/", model.ErrUrlDenied},
+		{"denied subdomain", "deny bad.example\n", "https://www.bad.example/", model.ErrUrlDenied},
+		{"not allowed", "allow 93.184.216.34\n", "https://93.184.216.35/", model.ErrUrlDenied},
+		{"allowed", "# only this one\nallow 93.184.216.34\n", "https://93.184.216.34/", nil},
+		{"deny wins over allow", "allow example.com\ndeny bad.example.com\n", "https://bad.example.com/", model.ErrUrlDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, tt.list)
+			if err := s.Check(context.Background(), tt.rawUrl); err != tt.want {
+				t.Errorf("Check(%q) = %v, want %v", tt.rawUrl, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDomainList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"entries and comments", "# comment\n\ndeny bad.example\nALLOW https://good.example/\n", true},
+		{"unknown action", "block bad.example\n", false},
+		{"missing domain", "deny\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "domains.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadDomainList(path)
+			if (err == nil) != tt.valid {
+				t.Errorf("loadDomainList() error = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestHostname(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"Tutle.Example", "tutle.example"},
+		{"https://tutle.example:8443/path", "tutle.example"},
+		{" tutle.example. ", "tutle.example"},
+	}
+	for _, tt := range tests {
+		if got := hostname(tt.s); got != tt.want {
+			t.Errorf("hostname(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
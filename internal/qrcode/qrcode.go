@@ -0,0 +1,100 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"privaTutle/model"
+
+	"rsc.io/qr"
+)
+
+var levels = map[string]qr.Level{
+	"L": qr.L,
+	"M": qr.M,
+	"Q": qr.Q,
+	"H": qr.H,
+}
+
+type Options struct {
+	// Size is the requested width of the image in pixels. The result is
+	// rounded down to a whole number of pixels per module.
+	Size int
+	// Margin is the quiet zone around the code, in modules.
+	Margin int
+	// Level is the error correction level, one of L, M, Q or H.
+	Level string
+}
+
+func encode(content string, opts Options) (*qr.Code, int, error) {
+	level, ok := levels[opts.Level]
+	if !ok {
+		return nil, 0, model.ErrParameter
+	}
+
+	code, err := qr.Encode(content, level)
+	if err != nil {
+		return nil, 0, model.ErrParameter
+	}
+
+	scale := opts.Size / (code.Size + 2*opts.Margin)
+	if scale < 1 {
+		scale = 1
+	}
+
+	return code, scale, nil
+}
+
+func PNG(content string, opts Options) ([]byte, error) {
+	code, scale, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	side := (code.Size + 2*opts.Margin) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+opts.Margin)*scale+dx, (y+opts.Margin)*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err = png.Encode(buf, img); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return buf.Bytes(), nil
+}
+
+func SVG(content string, opts Options) ([]byte, error) {
+	code, scale, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := code.Size + 2*opts.Margin
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		modules*scale, modules*scale, modules, modules)
+	fmt.Fprintf(buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(buf, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
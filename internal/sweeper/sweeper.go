@@ -0,0 +1,133 @@
+package sweeper
+
+import (
+	"context"
+	"log"
+	"privaTutle/internal/mediastore"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Interval is how often expired media are swept, 0 disables sweeping.
+	Interval time.Duration
+	// Batch is the most media removed per round.
+	Batch int
+}
+
+// Result counts what a sweep removed.
+type Result struct {
+	// Media is how many media had their content removed.
+	Media int `json:"media"`
+	// Bytes is the size of the removed content.
+	Bytes int64 `json:"bytes"`
+	// Failed is how many media could not be removed, they are retried once
+	// their lease has passed.
+	Failed int `json:"failed"`
+}
+
+// Stats adds up the sweeps of this instance.
+type Stats struct {
+	Runs      int64     `json:"runs"`
+	Media     int64     `json:"media"`
+	Bytes     int64     `json:"bytes"`
+	Failed    int64     `json:"failed"`
+	LastRun   time.Time `json:"lastRun"`
+	LastSweep Result    `json:"lastSweep"`
+}
+
+// expiredMedia is the part of the media store that is swept.
+type expiredMedia interface {
+	ClaimExpired(ctx context.Context, lease time.Duration) (*mediastore.Media, error)
+	RemoveExpired(ctx context.Context, data *mediastore.Media) error
+}
+
+type sweeperService struct {
+	cnf   Config
+	media expiredMedia
+
+	mu    sync.Mutex
+	stats Stats
+	once  sync.Once
+}
+
+var SweeperService *sweeperService
+
+// leaseDuration keeps other instances away from media being removed.
+const leaseDuration = 5 * time.Minute
+
+// NewSweeperService sweeps the media store, which has to be built first.
+func NewSweeperService(cnf Config) {
+	if cnf.Batch <= 0 {
+		cnf.Batch = 100
+	}
+
+	SweeperService = &sweeperService{cnf: cnf, media: mediastore.MediaStoreService}
+}
+
+// Start runs the sweeper in the background. Calling it again has no effect.
+func (s *sweeperService) Start() {
+	if s.cnf.Interval <= 0 {
+		return
+	}
+	s.once.Do(func() {
+		go s.run()
+	})
+}
+
+func (s *sweeperService) run() {
+	ticker := time.NewTicker(s.cnf.Interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		result, err := s.Sweep(context.Background())
+		if err != nil {
+			log.Println("sweeper: sweep:", err)
+		}
+		if result.Media > 0 || result.Failed > 0 {
+			log.Println("sweeper: removed", result.Media, "expired media,", result.Bytes, "bytes,", result.Failed, "failed")
+		}
+	}
+}
+
+// Sweep removes the content of up to one batch of expired media. Every media
+// is leased before it is removed, so several instances can sweep at the same
+// time without removing anything twice.
+func (s *sweeperService) Sweep(ctx context.Context) (Result, error) {
+	result := Result{}
+	var err error
+	for result.Media+result.Failed < s.cnf.Batch {
+		var data *mediastore.Media
+		data, err = s.media.ClaimExpired(ctx, leaseDuration)
+		if err != nil || data == nil {
+			break
+		}
+
+		if err = s.media.RemoveExpired(ctx, data); err != nil {
+			log.Println("sweeper: remove", data.ShortUrl+":", err)
+			result.Failed++
+			err = nil
+			continue
+		}
+		result.Media++
+		result.Bytes += data.Size
+	}
+
+	s.mu.Lock()
+	s.stats.Runs++
+	s.stats.Media += int64(result.Media)
+	s.stats.Bytes += result.Bytes
+	s.stats.Failed += int64(result.Failed)
+	s.stats.LastRun = time.Now()
+	s.stats.LastSweep = result
+	s.mu.Unlock()
+
+	return result, err
+}
+
+// Stats returns the counts of all sweeps since the start of this instance.
+func (s *sweeperService) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
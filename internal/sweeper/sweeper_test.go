@@ -0,0 +1,92 @@
+package sweeper
+
+import (
+	"context"
+	"errors"
+	"privaTutle/internal/mediastore"
+	"privaTutle/model"
+	"testing"
+	"time"
+)
+
+// fakeMedia hands out expired media in order, removing those not in fail.
+type fakeMedia struct {
+	expired []*mediastore.Media
+	fail    map[string]bool
+	err     error
+	removed []string
+}
+
+func (f *fakeMedia) ClaimExpired(ctx context.Context, lease time.Duration) (*mediastore.Media, error) {
+	if len(f.expired) == 0 {
+		return nil, f.err
+	}
+	data := f.expired[0]
+	f.expired = f.expired[1:]
+	return data, nil
+}
+
+func (f *fakeMedia) RemoveExpired(ctx context.Context, data *mediastore.Media) error {
+	if f.fail[data.ShortUrl] {
+		return model.ErrInternal
+	}
+	f.removed = append(f.removed, data.ShortUrl)
+	return nil
+}
+
+func expired(sizes ...int64) []*mediastore.Media {
+	data := []*mediastore.Media{}
+	for i, size := range sizes {
+		data = append(data, &mediastore.Media{ShortUrl: string(rune('a' + i)), Size: size})
+	}
+	return data
+}
+
+func TestSweep(t *testing.T) {
+	errClaim := errors.New("claim failed")
+	tests := []struct {
+		name    string
+		media   *fakeMedia
+		batch   int
+		want    Result
+		err     error
+		removed int
+	}{
+		{"nothing expired", &fakeMedia{}, 10, Result{}, nil, 0},
+		{"all removed", &fakeMedia{expired: expired(10, 20, 30)}, 10, Result{Media: 3, Bytes: 60}, nil, 3},
+		{"failures are counted", &fakeMedia{expired: expired(10, 20, 30), fail: map[string]bool{"b": true}}, 10, Result{Media: 2, Bytes: 40, Failed: 1}, nil, 2},
+		{"one batch", &fakeMedia{expired: expired(1, 1, 1, 1, 1)}, 2, Result{Media: 2, Bytes: 2}, nil, 2},
+		{"failures count towards the batch", &fakeMedia{expired: expired(1, 1, 1), fail: map[string]bool{"a": true}}, 2, Result{Media: 1, Bytes: 1, Failed: 1}, nil, 1},
+		{"claim error", &fakeMedia{expired: expired(5), err: errClaim}, 10, Result{Media: 1, Bytes: 5}, errClaim, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sweeperService{cnf: Config{Batch: tt.batch}, media: tt.media}
+			result, err := s.Sweep(context.Background())
+			if err != tt.err {
+				t.Fatalf("Sweep() error = %v, want %v", err, tt.err)
+			}
+			if result != tt.want {
+				t.Errorf("Sweep() = %+v, want %+v", result, tt.want)
+			}
+			if len(tt.media.removed) != tt.removed {
+				t.Errorf("removed %v, want %d media", tt.media.removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	media := &fakeMedia{expired: expired(10, 20, 30), fail: map[string]bool{"c": true}}
+	s := &sweeperService{cnf: Config{Batch: 2}, media: media}
+	s.Sweep(context.Background())
+	s.Sweep(context.Background())
+
+	stats := s.Stats()
+	if stats.Runs != 2 || stats.Media != 2 || stats.Bytes != 30 || stats.Failed != 1 {
+		t.Errorf("Stats() = %+v, want 2 runs removing 2 media of 30 bytes and 1 failure", stats)
+	}
+	if stats.LastSweep != (Result{Failed: 1}) {
+		t.Errorf("LastSweep = %+v, want the one failure of the second run", stats.LastSweep)
+	}
+}
@@ -0,0 +1,223 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"privaTutle/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one short link as it is exported and imported.
+type Record struct {
+	Name        string    `json:"name"`
+	Code        string    `json:"code"`
+	Destination string    `json:"destination"`
+	CreateTime  time.Time `json:"createTime"`
+	Status      string    `json:"status"`
+	Clicks      int64     `json:"clicks"`
+	Tags        []string  `json:"tags"`
+	Folder      string    `json:"folder"`
+}
+
+var header = []string{"name", "code", "destination", "createTime", "status", "clicks", "tags", "folder"}
+
+// WriteCSV writes records in the export csv format. Tags are joined with ";".
+func WriteCSV(w io.Writer, records []*Record) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return model.ErrInternal
+	}
+	for _, r := range records {
+		err := writer.Write([]string{
+			r.Name,
+			r.Code,
+			r.Destination,
+			r.CreateTime.UTC().Format(time.RFC3339),
+			r.Status,
+			strconv.FormatInt(r.Clicks, 10),
+			strings.Join(r.Tags, ";"),
+			r.Folder,
+		})
+		if err != nil {
+			return model.ErrInternal
+		}
+	}
+	writer.Flush()
+	if writer.Error() != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+// columns maps the normalized header names of the supported csv files to
+// record fields. Next to our own export this covers the bit.ly link export,
+// which names its columns e.g. "Title", "Bitlink", "Long URL" and "Created".
+var columns = map[string]string{
+	"name":         "name",
+	"title":        "name",
+	"code":         "code",
+	"bitlink":      "code",
+	"link":         "code",
+	"shortlink":    "code",
+	"destination":  "destination",
+	"longurl":      "destination",
+	"url":          "destination",
+	"createtime":   "createTime",
+	"created":      "createTime",
+	"createdat":    "createTime",
+	"creationdate": "createTime",
+	"status":       "status",
+	"clicks":       "clicks",
+	"tags":         "tags",
+	"folder":       "folder",
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"1/2/2006 15:04",
+	"1/2/2006",
+}
+
+// Parse reads records from our json or csv export, or from a bit.ly csv
+// export. Entries that cannot be read are reported in rowErrs by their index
+// instead of failing the whole file.
+func Parse(data []byte) ([]*Record, map[int]error, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return parseJSON(trimmed)
+	}
+
+	return parseCSV(bytes.NewReader(data))
+}
+
+// parseJSON accepts the exported array, also when it is still wrapped in the
+// data field of an api response.
+func parseJSON(data []byte) ([]*Record, map[int]error, error) {
+	var records []*Record
+	if data[0] == '{' {
+		var wrapped struct {
+			Data []*Record `json:"data"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, nil, model.ErrParameter
+		}
+		records = wrapped.Data
+	} else if err := json.Unmarshal(data, &records); err != nil {
+		return nil, nil, model.ErrParameter
+	}
+
+	rowErrs := map[int]error{}
+	for i, r := range records {
+		if r == nil {
+			records[i] = &Record{}
+			rowErrs[i] = model.ErrParameter
+			continue
+		}
+		r.Code = code(r.Code)
+	}
+
+	return records, rowErrs, nil
+}
+
+func parseCSV(r io.Reader) ([]*Record, map[int]error, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	head, err := reader.Read()
+	if err != nil {
+		return nil, nil, model.ErrParameter
+	}
+	fields := map[string]int{}
+	for i, name := range head {
+		if field, ok := columns[normalize(name)]; ok {
+			if _, seen := fields[field]; !seen {
+				fields[field] = i
+			}
+		}
+	}
+	if _, ok := fields["destination"]; !ok {
+		return nil, nil, model.ErrParameter
+	}
+
+	var records []*Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, model.ErrParameter
+		}
+
+		field := func(name string) string {
+			if i, ok := fields[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		record := &Record{
+			Name:        field("name"),
+			Code:        code(field("code")),
+			Destination: field("destination"),
+			Status:      field("status"),
+			Tags:        tags(field("tags")),
+			Folder:      field("folder"),
+		}
+		// other exports write dates in all kinds of formats, a date that
+		// cannot be read only loses the original creation time
+		record.CreateTime, _ = parseTime(field("createTime"))
+		record.Clicks, _ = strconv.ParseInt(field("clicks"), 10, 64)
+		records = append(records, record)
+	}
+
+	return records, map[int]error{}, nil
+}
+
+func normalize(name string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// code takes the back-half of a full short link such as bit.ly/abc, bare
+// codes are returned as they are.
+func code(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	s = strings.TrimRight(s, "/")
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+func tags(s string) []string {
+	list := []string{}
+	for _, tag := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' || r == '|' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			list = append(list, tag)
+		}
+	}
+	return list
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, model.ErrParameter
+}
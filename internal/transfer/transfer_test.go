@@ -0,0 +1,128 @@
+package transfer
+
+import (
+	"bytes"
+	"privaTutle/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	records := []*Record{
+		{Name: "docs, v2", Code: "docs", Destination: "https://example.com/?a=1&b=2", CreateTime: created, Status: "active", Clicks: 12, Tags: []string{"work", "docs"}, Folder: "team"},
+		{Code: "go@links.example", Destination: "https://example.org/", CreateTime: created, Status: "delete", Tags: []string{}},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteCSV(buf, records); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, rowErrs, err := Parse(buf.Bytes())
+	if err != nil || len(rowErrs) != 0 {
+		t.Fatalf("Parse() error = %v, %v", err, rowErrs)
+	}
+	if !reflect.DeepEqual(parsed, records) {
+		t.Errorf("Parse(WriteCSV()) = %+v, want %+v", parsed, records)
+	}
+}
+
+func TestParseBitly(t *testing.T) {
+	data := "\xef\xbb\xbfTitle,Bitlink,Long URL,Created,Tags\n" +
+		"Launch,https://bit.ly/3abcDEF,https://example.com/launch,2023-11-05 10:00:00,\"news, launch\"\n" +
+		"Untitled,bit.ly/xyz/,https://example.com/x,yesterday,\n"
+
+	records, rowErrs, err := Parse([]byte(data))
+	if err != nil || len(rowErrs) != 0 {
+		t.Fatalf("Parse() error = %v, %v", err, rowErrs)
+	}
+	want := []*Record{
+		{Name: "Launch", Code: "3abcDEF", Destination: "https://example.com/launch", CreateTime: time.Date(2023, 11, 5, 10, 0, 0, 0, time.UTC), Tags: []string{"news", "launch"}},
+		{Name: "Untitled", Code: "xyz", Destination: "https://example.com/x", Tags: []string{}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Parse() = %+v, want %+v", records, want)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		want     []*Record
+		wantErrs map[int]error
+		err      error
+	}{
+		{"array", `[{"code":"abc","destination":"https://example.com/"}]`, []*Record{{Code: "abc", Destination: "https://example.com/"}}, map[int]error{}, nil},
+		{"api response", `{"data":[{"code":"https://s.example/abc","destination":"https://example.com/"}]}`, []*Record{{Code: "abc", Destination: "https://example.com/"}}, map[int]error{}, nil},
+		{"null entry", `[null,{"code":"abc"}]`, []*Record{{}, {Code: "abc"}}, map[int]error{0: model.ErrParameter}, nil},
+		{"broken", `[{"code":`, nil, nil, model.ErrParameter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, rowErrs, err := Parse([]byte(tt.data))
+			if err != tt.err {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(records, tt.want) || !reflect.DeepEqual(rowErrs, tt.wantErrs) {
+				t.Errorf("Parse() = %+v, %v, want %+v, %v", records, rowErrs, tt.want, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"no destination column", "name,code\nx,abc\n"},
+		{"broken quotes", "url\n\"https://example.com/\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Parse([]byte(tt.data)); err != model.ErrParameter {
+				t.Errorf("Parse() error = %v, want %v", err, model.ErrParameter)
+			}
+		})
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"abc", "abc"},
+		{" abc ", "abc"},
+		{"bit.ly/abc", "abc"},
+		{"https://bit.ly/abc/", "abc"},
+		{"abc@links.example", "abc@links.example"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := code(tt.s); got != tt.want {
+			t.Errorf("code(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		err   error
+	}{
+		{"2024-03-01T08:30:00Z", time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC), nil},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), nil},
+		{"3/1/2024 08:30", time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC), nil},
+		{"soon", time.Time{}, model.ErrParameter},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.value)
+		if err != tt.err || !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q) = %v, %v, want %v, %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package trash
+
+import (
+	"context"
+	"privaTutle/internal/analytics"
+	"privaTutle/internal/link"
+	"privaTutle/internal/mediastore"
+)
+
+// purger reaches the services that keep the items.
+type purger struct {
+	links interface {
+		PurgeLink(ctx context.Context, objectId, shortUrl string) (bool, error)
+	}
+	clicks interface {
+		DeleteClicks(ctx context.Context, shortUrl string) error
+	}
+	media interface {
+		PurgeMedia(ctx context.Context, objectId, shortUrl string) error
+	}
+}
+
+// newPurger uses the link, analytics and media store services, which have to
+// be built first.
+func newPurger() purger {
+	return purger{
+		links:  link.LinkService,
+		clicks: analytics.AnalyticsService,
+		media:  mediastore.MediaStoreService,
+	}
+}
+
+// purge permanently removes what this server stores about an item.
+//
+// Short links lose their destination, settings, history and clicks, media
+// their content and record. The clicks of a shared legacy code are kept for
+// the other owners.
+func (p purger) purge(ctx context.Context, item *Item) error {
+	switch item.Kind {
+	case KindShort:
+		own, err := p.links.PurgeLink(ctx, item.UserId, item.ShortUrl)
+		if err != nil || !own {
+			return err
+		}
+		return p.clicks.DeleteClicks(ctx, item.ShortUrl)
+	case KindMedia:
+		return p.media.PurgeMedia(ctx, item.UserId, item.ShortUrl)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,61 @@
+package trash
+
+import (
+	"context"
+	"privaTutle/model"
+	"testing"
+)
+
+// fakeServices records what a purge removed.
+type fakeServices struct {
+	own    bool
+	err    error
+	links  []string
+	clicks []string
+	media  []string
+}
+
+func (f *fakeServices) PurgeLink(ctx context.Context, objectId, shortUrl string) (bool, error) {
+	f.links = append(f.links, objectId+"/"+shortUrl)
+	return f.own, f.err
+}
+
+func (f *fakeServices) DeleteClicks(ctx context.Context, shortUrl string) error {
+	f.clicks = append(f.clicks, shortUrl)
+	return nil
+}
+
+func (f *fakeServices) PurgeMedia(ctx context.Context, objectId, shortUrl string) error {
+	f.media = append(f.media, objectId+"/"+shortUrl)
+	return nil
+}
+
+func TestPurge(t *testing.T) {
+	tests := []struct {
+		name       string
+		item       Item
+		services   fakeServices
+		err        error
+		wantLinks  int
+		wantClicks int
+		wantMedia  int
+	}{
+		{"own link", Item{Kind: KindShort, UserId: "u1", ShortUrl: "abc"}, fakeServices{own: true}, nil, 1, 1, 0},
+		{"shared copy keeps the clicks", Item{Kind: KindShort, UserId: "u2", ShortUrl: "abc"}, fakeServices{}, nil, 1, 0, 0},
+		{"failed purge keeps the clicks", Item{Kind: KindShort, UserId: "u1", ShortUrl: "abc"}, fakeServices{own: true, err: model.ErrInternal}, model.ErrInternal, 1, 0, 0},
+		{"media", Item{Kind: KindMedia, UserId: "u1", ShortUrl: "img"}, fakeServices{}, nil, 0, 0, 1},
+		{"unknown kind", Item{Kind: "other", UserId: "u1", ShortUrl: "abc"}, fakeServices{}, nil, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &tt.services
+			p := purger{links: f, clicks: f, media: f}
+			if err := p.purge(context.Background(), &tt.item); err != tt.err {
+				t.Fatalf("purge() error = %v, want %v", err, tt.err)
+			}
+			if len(f.links) != tt.wantLinks || len(f.clicks) != tt.wantClicks || len(f.media) != tt.wantMedia {
+				t.Errorf("purged links %v, clicks %v, media %v", f.links, f.clicks, f.media)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package trash
+
+import (
+	"context"
+	"log"
+	"privaTutle/internal/pagination"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	KindShort = "short"
+	KindMedia = "media"
+)
+
+// Item is a deleted short link or media that can be restored until PurgeTime.
+type Item struct {
+	Id         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind       string             `bson:"kind" json:"kind"`
+	ShortUrl   string             `bson:"shortUrl" json:"shortUrl"`
+	UserId     string             `bson:"userId" json:"userId"`
+	DeleteTime time.Time          `bson:"deleteTime" json:"deleteTime"`
+	PurgeTime  time.Time          `bson:"purgeTime" json:"purgeTime"`
+	// LeaseTime is set while an instance is purging the item.
+	LeaseTime time.Time `bson:"leaseTime,omitempty" json:"-"`
+}
+
+type Config struct {
+	// Retention is how long deleted items can be restored.
+	Retention time.Duration
+	// Interval is how often expired items are purged, 0 disables purging.
+	Interval time.Duration
+	// Batch is the most items purged per round.
+	Batch int
+}
+
+type trashService struct {
+	collection *mongo.Collection
+	cnf        Config
+	purger     purger
+}
+
+var TrashService *trashService
+
+// leaseDuration is how long a purge claim keeps other instances away from an
+// item. A claim that outlives it is taken over, so a crashed purge is retried.
+const leaseDuration = 5 * time.Minute
+
+func NewTrashService(database *mongo.Database, cnf Config) {
+	if cnf.Batch <= 0 {
+		cnf.Batch = 100
+	}
+
+	s := &trashService{
+		collection: database.Collection("trash"),
+		cnf:        cnf,
+		purger:     newPurger(),
+	}
+
+	// a user has one item per code
+	_, err := s.collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "kind", Value: 1}, {Key: "shortUrl", Value: 1}, {Key: "userId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if cnf.Interval > 0 {
+		go s.run()
+	}
+
+	TrashService = s
+}
+
+// itemFilter matches the trash item of objectId. Several users can delete
+// their copy of a shared legacy code, each has an item of their own.
+func itemFilter(kind, objectId, shortUrl string) bson.M {
+	return bson.M{"kind": kind, "shortUrl": shortUrl, "userId": objectId}
+}
+
+// AddItem moves a short link or media into the trash. Deleting it again
+// restarts its retention.
+func (s *trashService) AddItem(ctx context.Context, kind, objectId, shortUrl string) (*Item, error) {
+	now := time.Now()
+	filter := itemFilter(kind, objectId, shortUrl)
+	update := bson.M{
+		"$set": bson.M{
+			"deleteTime": now,
+			"purgeTime":  now.Add(s.cnf.Retention),
+		},
+		"$unset": bson.M{"leaseTime": ""},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	data := &Item{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+type itemPosition struct {
+	Id primitive.ObjectID `bson:"id"`
+}
+
+// ListUserItems lists the trash of a user, most recently deleted first. kind
+// is optional. It returns the cursor of the next page, or "" when there is none.
+func (s *trashService) ListUserItems(ctx context.Context, objectId, kind, cursor string, limit int64) ([]*Item, string, error) {
+	filter := bson.M{"userId": objectId, "purgeTime": bson.M{"$gt": time.Now()}}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+	if cursor != "" {
+		position := &itemPosition{}
+		err := pagination.Decode(cursor, position)
+		if err != nil {
+			return nil, "", err
+		}
+		filter["_id"] = bson.M{"$lt": position.Id}
+	}
+	opts := options.Find().SetSort(bson.M{"_id": -1}).SetLimit(limit + 1)
+
+	data, err := s.find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) <= limit {
+		return data, "", nil
+	}
+
+	data = data[:limit]
+	next, err := pagination.Encode(&itemPosition{Id: data[limit-1].Id})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, next, nil
+}
+
+// GetRestorableItem returns the trash item of a user that is still within
+// its retention window.
+func (s *trashService) GetRestorableItem(ctx context.Context, objectId, kind, shortUrl string) (*Item, error) {
+	data := &Item{}
+	err := s.collection.FindOne(ctx, itemFilter(kind, objectId, shortUrl)).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrTrashNotFound
+		}
+		return nil, model.ErrInternal
+	}
+	if !time.Now().Before(data.PurgeTime) {
+		return nil, model.ErrTrashExpired
+	}
+
+	return data, nil
+}
+
+func (s *trashService) RemoveItem(ctx context.Context, kind, objectId, shortUrl string) error {
+	_, err := s.collection.DeleteOne(ctx, itemFilter(kind, objectId, shortUrl))
+	if err != nil {
+		return model.ErrInternal
+	}
+
+	return nil
+}
+
+func (s *trashService) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*Item, error) {
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	data := []*Item{}
+	if err = cursor.All(ctx, &data); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+func (s *trashService) run() {
+	ticker := time.NewTicker(s.cnf.Interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := s.PurgeDue(context.Background())
+		if err != nil {
+			log.Println("trash: purge:", err)
+		}
+		if n > 0 {
+			log.Println("trash: purged", n, "items")
+		}
+	}
+}
+
+// PurgeDue permanently removes up to one batch of items whose retention has
+// passed and returns how many were removed. Every item is claimed with a lease
+// first, so several instances can purge at the same time.
+func (s *trashService) PurgeDue(ctx context.Context) (int, error) {
+	purged := 0
+	for purged < s.cnf.Batch {
+		item, err := s.claim(ctx)
+		if err != nil {
+			return purged, err
+		}
+		if item == nil {
+			break
+		}
+
+		err = s.purger.purge(ctx, item)
+		if err != nil {
+			log.Println("trash: purge", item.Kind, item.ShortUrl+":", err)
+			continue
+		}
+		if err = s.RemoveItem(ctx, item.Kind, item.UserId, item.ShortUrl); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (s *trashService) claim(ctx context.Context) (*Item, error) {
+	now := time.Now()
+	filter := bson.M{
+		"purgeTime": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"leaseTime": bson.M{"$exists": false}},
+			bson.M{"leaseTime": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"leaseTime": now.Add(leaseDuration)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"purgeTime": 1}).SetReturnDocument(options.After)
+
+	data := &Item{}
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
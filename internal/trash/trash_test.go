@@ -0,0 +1,15 @@
+package trash
+
+import "testing"
+
+func TestItemFilter(t *testing.T) {
+	// two owners of a shared legacy code delete their copies
+	first := itemFilter(KindShort, "u1", "abc")
+	second := itemFilter(KindShort, "u2", "abc")
+	if first["userId"] != "u1" || second["userId"] != "u2" {
+		t.Errorf("itemFilter() = %v, %v, want the items of each owner", first, second)
+	}
+	if first["kind"] != KindShort || first["shortUrl"] != "abc" {
+		t.Errorf("itemFilter() = %v, want the short abc", first)
+	}
+}
@@ -0,0 +1,346 @@
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"privaTutle/internal/mediastore"
+	"privaTutle/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Upload is a resumable upload. Every chunk is stored as a part of its own,
+// the parts are joined into the media once Offset reaches Length.
+type Upload struct {
+	// Id is the secret that addresses the upload, it is only known to the
+	// client that created it.
+	Id     string `bson:"_id" json:"id"`
+	UserId string `bson:"userId" json:"userId"`
+	Type   string `bson:"type" json:"type"`
+	// Password is the bcrypt hash of the media password.
+	Password       string `bson:"password,omitempty" json:"-"`
+	ExpirationTime int64  `bson:"expirationTime" json:"expirationTime"`
+	ContentType    string `bson:"contentType" json:"contentType"`
+	Length         int64  `bson:"length" json:"length"`
+	Offset         int64  `bson:"offset" json:"offset"`
+	Parts          []Part `bson:"parts" json:"-"`
+	// ShortUrl is set once the media was created.
+	ShortUrl   string    `bson:"shortUrl" json:"shortUrl"`
+	ExpireTime time.Time `bson:"expireTime" json:"expireTime"`
+	CreateTime time.Time `bson:"createTime" json:"createTime"`
+	// LeaseTime is set while an instance is joining the parts.
+	LeaseTime time.Time `bson:"leaseTime,omitempty" json:"-"`
+}
+
+type Part struct {
+	Object string `bson:"object"`
+	Size   int64  `bson:"size"`
+}
+
+// Done reports whether all bytes of the upload have arrived.
+func (u *Upload) Done() bool {
+	return u.Offset == u.Length
+}
+
+type Config struct {
+	// TTL is how long an upload can be resumed after it was created.
+	TTL time.Duration
+	// Interval is how often abandoned uploads are removed, 0 disables it.
+	Interval time.Duration
+}
+
+type uploadService struct {
+	collection *mongo.Collection
+	cnf        Config
+}
+
+var UploadService *uploadService
+
+// leaseDuration keeps other requests from joining the same upload twice. A
+// join that outlives it is retried by the next request.
+const leaseDuration = 30 * time.Minute
+
+func NewUploadService(database *mongo.Database, cnf Config) {
+	s := &uploadService{
+		collection: database.Collection("upload"),
+		cnf:        cnf,
+	}
+
+	if cnf.Interval > 0 {
+		go s.run()
+	}
+
+	UploadService = s
+}
+
+// CreateUpload starts an upload of length bytes.
+func (s *uploadService) CreateUpload(ctx context.Context, info *Upload) (*Upload, error) {
+	if info.Length <= 0 {
+		return nil, model.ErrUploadLength
+	}
+	if info.Length > mediastore.MediaStoreService.MaxSize() {
+		return nil, model.ErrMediaTooLarge
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, model.ErrInternal
+	}
+
+	now := time.Now()
+	data := &Upload{
+		Id:             hex.EncodeToString(b),
+		UserId:         info.UserId,
+		Type:           info.Type,
+		Password:       info.Password,
+		ExpirationTime: info.ExpirationTime,
+		Length:         info.Length,
+		Parts:          []Part{},
+		ExpireTime:     now.Add(s.cnf.TTL),
+		CreateTime:     now,
+	}
+	_, err := s.collection.InsertOne(ctx, data)
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// GetUpload returns an upload that can still be resumed. Uploads created with
+// an account can only be resumed with the same account.
+func (s *uploadService) GetUpload(ctx context.Context, objectId, id string) (*Upload, error) {
+	data := &Upload{}
+	err := s.collection.FindOne(ctx, bson.M{"_id": id, "expireTime": bson.M{"$gt": time.Now()}}).Decode(data)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrUploadNotFound
+		}
+		return nil, model.ErrInternal
+	}
+	if data.UserId != "" && data.UserId != objectId {
+		return nil, model.ErrUploadNotFound
+	}
+
+	return data, nil
+}
+
+// WriteChunk stores r as the part that continues the upload at offset. The
+// content type is taken from the first chunk. Chunks for an offset another
+// request has written first fail with model.ErrUploadOffset.
+func (s *uploadService) WriteChunk(ctx context.Context, u *Upload, offset int64, contentType string, r io.Reader) (*Upload, error) {
+	if offset != u.Offset || u.Done() {
+		return nil, model.ErrUploadOffset
+	}
+
+	object, size, err := mediastore.MediaStoreService.Put(ctx, "upload/"+u.Id+"/", "application/octet-stream", r, u.Length-u.Offset)
+	if err != nil {
+		if err == model.ErrMediaTooLarge {
+			return nil, model.ErrUploadLength
+		}
+		return nil, err
+	}
+	if size == 0 {
+		mediastore.MediaStoreService.DeleteObject(ctx, object)
+		return u, nil
+	}
+
+	set := bson.M{}
+	if offset == 0 {
+		set["contentType"] = contentType
+	}
+	update := bson.M{
+		"$inc":  bson.M{"offset": size},
+		"$push": bson.M{"parts": Part{Object: object, Size: size}},
+	}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Upload{}
+	err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": u.Id, "offset": offset}, update, opts).Decode(data)
+	if err != nil {
+		mediastore.MediaStoreService.DeleteObject(ctx, object)
+		if err == mongo.ErrNoDocuments {
+			return nil, model.ErrUploadOffset
+		}
+		return nil, model.ErrInternal
+	}
+
+	return data, nil
+}
+
+// Complete joins the parts of a finished upload into its media. Completing an
+// upload again returns the media that was already created.
+func (s *uploadService) Complete(ctx context.Context, u *Upload) (*mediastore.Media, error) {
+	if !u.Done() {
+		return nil, model.ErrUploadOffset
+	}
+	if u.ShortUrl != "" {
+		return mediastore.MediaStoreService.GetMedia(ctx, u.ShortUrl)
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id":      u.Id,
+		"shortUrl": "",
+		"$or": bson.A{
+			bson.M{"leaseTime": bson.M{"$exists": false}},
+			bson.M{"leaseTime": bson.M{"$lt": now}},
+		},
+	}
+	result, err := s.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"leaseTime": now.Add(leaseDuration)}})
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	if result.ModifiedCount == 0 {
+		// another request is joining the parts
+		return nil, model.ErrUploadOffset
+	}
+
+	reader := &partsReader{ctx: ctx, parts: u.Parts}
+	defer reader.Close()
+	object, size, err := mediastore.MediaStoreService.Put(ctx, "media/", u.ContentType, reader, u.Length)
+	if err == nil && size != u.Length {
+		mediastore.MediaStoreService.DeleteObject(ctx, object)
+		err = model.ErrUploadLength
+	}
+	if err != nil {
+		s.collection.UpdateOne(ctx, bson.M{"_id": u.Id}, bson.M{"$unset": bson.M{"leaseTime": ""}})
+		return nil, err
+	}
+
+	data, err := mediastore.MediaStoreService.CreateMedia(ctx, &mediastore.Media{
+		UserId:      u.UserId,
+		Type:        u.Type,
+		Object:      object,
+		ContentType: u.ContentType,
+		Size:        size,
+		Password:    u.Password,
+	}, u.ExpirationTime)
+	if err != nil {
+		mediastore.MediaStoreService.DeleteObject(ctx, object)
+		s.collection.UpdateOne(ctx, bson.M{"_id": u.Id}, bson.M{"$unset": bson.M{"leaseTime": ""}})
+		return nil, err
+	}
+
+	_, err = s.collection.UpdateOne(ctx, bson.M{"_id": u.Id}, bson.M{
+		"$set":   bson.M{"shortUrl": data.ShortUrl, "parts": []Part{}},
+		"$unset": bson.M{"leaseTime": ""},
+	})
+	if err != nil {
+		return nil, model.ErrInternal
+	}
+	s.deleteParts(ctx, u.Parts)
+
+	return data, nil
+}
+
+// DeleteUpload abandons an upload and its parts.
+func (s *uploadService) DeleteUpload(ctx context.Context, u *Upload) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": u.Id})
+	if err != nil {
+		return model.ErrInternal
+	}
+	s.deleteParts(ctx, u.Parts)
+
+	return nil
+}
+
+func (s *uploadService) deleteParts(ctx context.Context, parts []Part) {
+	for _, part := range parts {
+		if err := mediastore.MediaStoreService.DeleteObject(ctx, part.Object); err != nil {
+			log.Println("upload: delete part", part.Object+":", err)
+		}
+	}
+}
+
+func (s *uploadService) run() {
+	ticker := time.NewTicker(s.cnf.Interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := s.RemoveExpired(context.Background())
+		if err != nil {
+			log.Println("upload: remove expired:", err)
+		}
+		if n > 0 {
+			log.Println("upload: removed", n, "expired uploads")
+		}
+	}
+}
+
+// RemoveExpired deletes uploads that can no longer be resumed together with
+// their parts, and returns how many were removed.
+func (s *uploadService) RemoveExpired(ctx context.Context) (int, error) {
+	opts := options.Find().SetLimit(100)
+	cursor, err := s.collection.Find(ctx, bson.M{"expireTime": bson.M{"$lte": time.Now()}}, opts)
+	if err != nil {
+		return 0, model.ErrInternal
+	}
+	defer cursor.Close(ctx)
+
+	data := []*Upload{}
+	if err = cursor.All(ctx, &data); err != nil {
+		return 0, model.ErrInternal
+	}
+
+	removed := 0
+	for _, u := range data {
+		if err = s.DeleteUpload(ctx, u); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// partsReader reads the parts of an upload one after the other, only one
+// part is open at a time.
+type partsReader struct {
+	ctx     context.Context
+	parts   []Part
+	current io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			current, err := mediastore.MediaStoreService.Open(r.ctx, r.parts[0].Object)
+			if err != nil {
+				return 0, err
+			}
+			r.current = current
+			r.parts = r.parts[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
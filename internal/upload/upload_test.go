@@ -0,0 +1,146 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"privaTutle/internal/blob"
+	"privaTutle/internal/mediastore"
+	"privaTutle/model"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestMedia builds the media store on a local directory. The database is
+// never reached, the client only connects on its first operation.
+func newTestMedia(t *testing.T, maxSize int64) string {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	store, err := blob.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediastore.NewMediaStoreService(client.Database("test"), store, nil, mediastore.Config{MaxSize: maxSize, SigningKey: "test"})
+	return dir
+}
+
+func putParts(t *testing.T, chunks ...string) []Part {
+	parts := []Part{}
+	for _, chunk := range chunks {
+		object, size, err := mediastore.MediaStoreService.Put(context.Background(), "upload/test/", "application/octet-stream", strings.NewReader(chunk), int64(len(chunk)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, Part{Object: object, Size: size})
+	}
+	return parts
+}
+
+func TestPartsReader(t *testing.T) {
+	newTestMedia(t, 1<<20)
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"no parts", nil, ""},
+		{"one part", []string{"hello"}, "hello"},
+		{"parts in order", []string{"he", "llo", " ", "world"}, "hello world"},
+		{"empty part", []string{"a", "", "b"}, "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &partsReader{ctx: context.Background(), parts: putParts(t, tt.chunks...)}
+			defer reader.Close()
+			b, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("joined %q, want %q", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartsReaderMissingPart(t *testing.T) {
+	newTestMedia(t, 1<<20)
+	parts := append(putParts(t, "abc"), Part{Object: "upload/test/gone", Size: 3})
+	reader := &partsReader{ctx: context.Background(), parts: parts}
+	defer reader.Close()
+	if _, err := io.ReadAll(reader); err != model.ErrMediaNotFound {
+		t.Errorf("reading a missing part = %v, want %v", err, model.ErrMediaNotFound)
+	}
+}
+
+func TestWriteChunkChecks(t *testing.T) {
+	dir := newTestMedia(t, 1<<20)
+	s := &uploadService{}
+	tests := []struct {
+		name   string
+		upload Upload
+		offset int64
+		chunk  string
+		err    error
+	}{
+		{"behind the offset", Upload{Id: "u1", Length: 10, Offset: 4}, 0, "abcd", model.ErrUploadOffset},
+		{"ahead of the offset", Upload{Id: "u2", Length: 10, Offset: 4}, 6, "ab", model.ErrUploadOffset},
+		{"done", Upload{Id: "u3", Length: 4, Offset: 4}, 4, "a", model.ErrUploadOffset},
+		{"past the length", Upload{Id: "u4", Length: 10, Offset: 8}, 8, "abc", model.ErrUploadLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.WriteChunk(context.Background(), &tt.upload, tt.offset, "video/mp4", strings.NewReader(tt.chunk))
+			if err != tt.err {
+				t.Fatalf("WriteChunk() error = %v, want %v", err, tt.err)
+			}
+			// a refused chunk leaves no part behind
+			entries, _ := os.ReadDir(filepath.Join(dir, "upload", tt.upload.Id))
+			if len(entries) != 0 {
+				t.Errorf("refused chunk left %d parts", len(entries))
+			}
+		})
+	}
+}
+
+func TestCreateUploadLength(t *testing.T) {
+	newTestMedia(t, 100)
+	s := &uploadService{}
+	tests := []struct {
+		length int64
+		err    error
+	}{
+		{0, model.ErrUploadLength},
+		{-1, model.ErrUploadLength},
+		{101, model.ErrMediaTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := s.CreateUpload(context.Background(), &Upload{Length: tt.length}); err != tt.err {
+			t.Errorf("CreateUpload(length %d) error = %v, want %v", tt.length, err, tt.err)
+		}
+	}
+}
+
+func TestDone(t *testing.T) {
+	tests := []struct {
+		offset, length int64
+		want           bool
+	}{
+		{0, 10, false},
+		{9, 10, false},
+		{10, 10, true},
+	}
+	for _, tt := range tests {
+		u := &Upload{Offset: tt.offset, Length: tt.length}
+		if got := u.Done(); got != tt.want {
+			t.Errorf("Upload{Offset: %d, Length: %d}.Done() = %v, want %v", tt.offset, tt.length, got, tt.want)
+		}
+	}
+}
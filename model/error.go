@@ -5,4 +5,46 @@ import "errors"
 var (
 	ErrInternal  = errors.New("ErrInternal")
 	ErrParameter = errors.New("ErrParameter")
+	ErrCursor    = errors.New("ErrCursor")
+
+	ErrShortNotFound        = errors.New("ErrShortNotFound")
+	ErrShortDeleted         = errors.New("ErrShortDeleted")
+	ErrAliasTaken           = errors.New("ErrAliasTaken")
+	ErrShortCodeExhausted   = errors.New("ErrShortCodeExhausted")
+	ErrShortVersionNotFound = errors.New("ErrShortVersionNotFound")
+	ErrShortNotActive       = errors.New("ErrShortNotActive")
+	ErrShortExpired         = errors.New("ErrShortExpired")
+	ErrShortExhausted       = errors.New("ErrShortExhausted")
+	ErrShortPreview         = errors.New("ErrShortPreview")
+	ErrShortShared          = errors.New("ErrShortShared")
+
+	ErrDomainNotFound   = errors.New("ErrDomainNotFound")
+	ErrDomainTaken      = errors.New("ErrDomainTaken")
+	ErrDomainUnverified = errors.New("ErrDomainUnverified")
+	ErrDomainInUse      = errors.New("ErrDomainInUse")
+
+	ErrClaimNotFound = errors.New("ErrClaimNotFound")
+
+	ErrTrashNotFound = errors.New("ErrTrashNotFound")
+	ErrTrashExpired  = errors.New("ErrTrashExpired")
+
+	ErrShortPasswordRequired = errors.New("ErrShortPasswordRequired")
+	ErrShortPassword         = errors.New("ErrShortPassword")
+
+	ErrMediaNotFound  = errors.New("ErrMediaNotFound")
+	ErrMediaExpired   = errors.New("ErrMediaExpired")
+	ErrMediaPassword  = errors.New("ErrMediaPassword")
+	ErrMediaTooLarge  = errors.New("ErrMediaTooLarge")
+	ErrMediaSignature = errors.New("ErrMediaSignature")
+
+	ErrObjectNotFound = errors.New("ErrObjectNotFound")
+
+	ErrUploadNotFound = errors.New("ErrUploadNotFound")
+	ErrUploadOffset   = errors.New("ErrUploadOffset")
+	ErrUploadLength   = errors.New("ErrUploadLength")
+
+	ErrUrlScheme  = errors.New("ErrUrlScheme")
+	ErrUrlPrivate = errors.New("ErrUrlPrivate")
+	ErrUrlLoop    = errors.New("ErrUrlLoop")
+	ErrUrlDenied  = errors.New("ErrUrlDenied")
 )
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"privaTutle/internal/claim"
+	"privaTutle/internal/link"
+	"privaTutle/internal/mediastore"
+	"privaTutle/model"
+	"privaTutle/pkg/auth"
+	"time"
+
+	httpHelper "privaTutle/pkg/http_helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+)
+
+// claimTokenHeader carries the claim token of anonymous media uploads, whose
+// response body is the media itself.
+const claimTokenHeader = "X-Claim-Token"
+
+type ClaimInfo struct {
+	Tokens []string `validate:"required,min=1,max=100,dive,required"`
+}
+
+type ClaimResult struct {
+	Index    int    `json:"index"`
+	Kind     string `json:"kind,omitempty"`
+	ShortUrl string `json:"shortUrl,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// @Summary Claim
+// @Description Attaches short links and media created without Authorization to the account, using the claim tokens returned when they were created.
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  body  body  ClaimInfo  true  "body"
+// @Success 200
+// @Router /api/user/claim [post]
+func Claim(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	info := ClaimInfo{}
+	g.BindJSON(&info)
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	results := make([]ClaimResult, len(info.Tokens))
+	for i, claimToken := range info.Tokens {
+		results[i].Index = i
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		data, err := claim.ClaimService.Claim(ctx, objectId, claimToken)
+		if err == nil {
+			results[i].Kind = data.Kind
+			results[i].ShortUrl = data.ShortUrl
+			if data.Kind == claim.KindShort {
+				_, err = link.LinkService.ClaimLink(ctx, objectId, data.ShortUrl)
+			}
+			if data.Kind == claim.KindMedia {
+				_, err = mediastore.MediaStoreService.ClaimMedia(ctx, objectId, data.ShortUrl)
+			}
+			// the token stays usable when the item could not be attached
+			if err != nil {
+				if releaseErr := claim.ClaimService.Release(ctx, objectId, claimToken); releaseErr != nil {
+					err = releaseErr
+				}
+			}
+		}
+		cancel()
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	httpHelper.SendResponse(g, gin.H{
+		"data": results,
+	})
+}
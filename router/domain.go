@@ -0,0 +1,206 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"privaTutle/internal/customdomain"
+	"privaTutle/internal/link"
+	"privaTutle/model"
+	"privaTutle/pkg/auth"
+	"time"
+
+	httpHelper "privaTutle/pkg/http_helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+)
+
+type DomainInfo struct {
+	Host string `validate:"required,fqdn,max=253"`
+}
+
+// domainResponse adds the TXT record that has to be published to verify d.
+func domainResponse(d *customdomain.Domain) gin.H {
+	name, value := d.Record()
+	return gin.H{
+		"data": d,
+		"record": gin.H{
+			"type":  "TXT",
+			"name":  name,
+			"value": value,
+		},
+	}
+}
+
+func domainStatus(err error) int {
+	switch err {
+	case model.ErrDomainNotFound:
+		return http.StatusNotFound
+	case model.ErrDomainTaken, model.ErrDomainInUse:
+		return http.StatusConflict
+	case model.ErrDomainUnverified:
+		return http.StatusUnprocessableEntity
+	case model.ErrInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// @Summary AddDomain
+// @Description Registers a custom domain. Publish the returned TXT record and call VerifyDomain, then point the domain at this server to serve short links created with it.
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  body  body  DomainInfo  true  "body"
+// @Success 200
+// @Router /api/user/domain [post]
+func AddDomain(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	info := DomainInfo{}
+	g.BindJSON(&info)
+	info.Host = customdomain.Normalize(info.Host)
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := customdomain.DomainService.AddDomain(ctx, objectId, info.Host)
+	if err != nil {
+		httpHelper.SendError(g, domainStatus(err), err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, domainResponse(data))
+}
+
+// @Summary DomainList
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Success 200
+// @Router /api/user/domain [get]
+func DomainList(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := customdomain.DomainService.ListUserDomains(ctx, objectId)
+	if err != nil {
+		httpHelper.SendError(g, domainStatus(err), err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{
+		"data": data,
+	})
+}
+
+// @Summary VerifyDomain
+// @Description Checks the TXT record of the domain. The first account to verify a domain keeps it.
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  host  path  string  true  "host"
+// @Success 200
+// @Router /api/user/domain/{host}/verify [post]
+func VerifyDomain(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := customdomain.DomainService.VerifyDomain(ctx, objectId, g.Param("host"))
+	if err != nil {
+		httpHelper.SendError(g, domainStatus(err), err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, domainResponse(data))
+}
+
+// @Summary DeleteDomain
+// @Description Domains can only be removed once no short link uses them anymore, links in the trash count until they are purged.
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  host  path  string  true  "host"
+// @Success 200
+// @Router /api/user/domain/{host} [delete]
+func DeleteDomain(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := customdomain.DomainService.GetUserDomain(ctx, objectId, g.Param("host"))
+	if err != nil {
+		httpHelper.SendError(g, domainStatus(err), err.Error())
+		return
+	}
+	inUse, err := link.LinkService.HasDomainLinks(ctx, data.Host)
+	if err != nil {
+		httpHelper.SendError(g, domainStatus(err), err.Error())
+		return
+	}
+	if inUse {
+		httpHelper.SendError(g, http.StatusConflict, model.ErrDomainInUse.Error())
+		return
+	}
+
+	err = customdomain.DomainService.DeleteDomain(ctx, objectId, data.Host)
+	if err != nil {
+		httpHelper.SendError(g, domainStatus(err), err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, nil)
+}
@@ -4,12 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"privaTutle/internal/link"
+	"privaTutle/internal/mediastore"
 	"privaTutle/model"
 	fileHelper "privaTutle/pkg/file_helper"
-	"privaTutle/pkg/hash"
 	httpHelper "privaTutle/pkg/http_helper"
-	"privaTutle/service/media"
-	"privaTutle/service/short"
 	"privaTutle/service/user"
 	"strconv"
 	"strings"
@@ -18,17 +17,17 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator"
 	"github.com/line/line-bot-sdk-go/v7/linebot"
 	"github.com/spf13/viper"
 )
 
 var lineClient *linebot.Client
-var domain, intr, aboutUs string
+var domain, apiHost, intr, aboutUs string
 
 func NewLineRouter(group *gin.RouterGroup, bot *linebot.Client, cnf *viper.Viper) {
 	lineClient = bot
 	domain = cnf.GetString("frontend.host")
+	apiHost = cnf.GetString("api.host")
 	intr = cnf.GetString("line.intr")
 	aboutUs = cnf.GetString("line.aboutUs")
 	group.POST("", LineCallback)
@@ -76,7 +75,15 @@ func LineCallback(g *gin.Context) {
 						return
 					}
 
-					result := fmt.Sprintf("媒體檔案可瀏覽秒數: %d\n媒體檔案瀏覽密碼: %s", userSetting.ExpirationTime, userSetting.Password)
+					linkSetting, err := link.LinkService.GetLinkSetting(ctx, event.Source.UserID)
+					if err != nil {
+						if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("發生未知錯誤∑(✘Д✘๑ )")).Do(); err != nil {
+							return
+						}
+						return
+					}
+
+					result := fmt.Sprintf("媒體檔案可瀏覽秒數: %d\n媒體檔案瀏覽密碼: %s\n短網址有效秒數: %d", userSetting.ExpirationTime, userSetting.Password, linkSetting.Lifetime)
 					if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage(result)).Do(); err != nil {
 						return
 					}
@@ -138,6 +145,40 @@ func LineCallback(g *gin.Context) {
 							return
 						}
 
+					case "set life":
+						input = input[index+1:]
+						var lifetime int64
+						if input != "none" {
+							lifetime, err = strconv.ParseInt(input, 10, 64)
+							if err != nil {
+								if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("無效的輸入(๑╹◡╹๑)")).Do(); err != nil {
+									return
+								}
+								return
+							}
+						}
+
+						if lifetime < 0 || lifetime > 31536000 {
+							if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("無效的輸入(๑╹◡╹๑)")).Do(); err != nil {
+								return
+							}
+							return
+						}
+
+						linkSetting, err := link.LinkService.UpdateLinkLifetime(ctx, event.Source.UserID, lifetime)
+						if err != nil {
+							if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("發生未知錯誤∑(✘Д✘๑ )")).Do(); err != nil {
+								return
+							}
+							return
+						}
+
+						result := fmt.Sprintf("成功設定短網址有效秒數: %d", linkSetting.Lifetime)
+
+						if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage(result)).Do(); err != nil {
+							return
+						}
+
 					case "set pass":
 						input = input[index+1:]
 						if input != "none" && input != "today" {
@@ -179,7 +220,7 @@ func LineCallback(g *gin.Context) {
 						info := ShortInfo{}
 						info.LeadUrl = message.Text
 
-						validate := validator.New()
+						validate := newShortValidator()
 						err = validate.Struct(info)
 						if err != nil {
 							if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("無效的輸入(๑╹◡╹๑)")).Do(); err != nil {
@@ -188,9 +229,7 @@ func LineCallback(g *gin.Context) {
 							return
 						}
 
-						shortUrl := hash.StringHash(info.LeadUrl)
-
-						data, err := short.ShortService.CreateShort(ctx, event.Source.UserID, shortUrl, info.LeadUrl)
+						linkSetting, err := link.LinkService.GetLinkSetting(ctx, event.Source.UserID)
 						if err != nil {
 							if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("發生未知錯誤∑(✘Д✘๑ )")).Do(); err != nil {
 								return
@@ -198,7 +237,24 @@ func LineCallback(g *gin.Context) {
 							return
 						}
 
-						if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage(domain+data.ShortUrl)).Do(); err != nil {
+						if linkSetting.Lifetime > 0 {
+							info.ExpiresAt = time.Now().Add(time.Duration(linkSetting.Lifetime) * time.Second)
+						}
+
+						shortUrl, _, err := createShort(ctx, event.Source.UserID, info)
+						if err != nil {
+							reply := "發生未知錯誤∑(✘Д✘๑ )"
+							switch err {
+							case model.ErrUrlScheme, model.ErrUrlPrivate, model.ErrUrlLoop, model.ErrUrlDenied:
+								reply = "此網址無法縮短(๑•́ ₃ •̀๑)"
+							}
+							if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage(reply)).Do(); err != nil {
+								return
+							}
+							return
+						}
+
+						if _, err = lineClient.ReplyMessage(event.ReplyToken, linkMessages("short", shortUrl)...).Do(); err != nil {
 							return
 						}
 
@@ -240,7 +296,7 @@ func LineCallback(g *gin.Context) {
 					}
 				}
 
-				data, err := media.MediaService.CreateMedia(ctx, event.Source.UserID, "image", userSetting.Password, userSetting.ExpirationTime, buf.Bytes())
+				data, err := createLineMedia(ctx, event.Source.UserID, "image", userSetting.Password, userSetting.ExpirationTime, buf.Bytes())
 				if err != nil {
 					if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("發生未知錯誤∑(✘Д✘๑ )")).Do(); err != nil {
 						return
@@ -248,7 +304,7 @@ func LineCallback(g *gin.Context) {
 					return
 				}
 
-				if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage(domain+data.ShortUrl)).Do(); err != nil {
+				if _, err = lineClient.ReplyMessage(event.ReplyToken, linkMessages("media", data.ShortUrl)...).Do(); err != nil {
 					return
 				}
 
@@ -276,7 +332,7 @@ func LineCallback(g *gin.Context) {
 					return
 				}
 
-				data, err := media.MediaService.CreateMedia(ctx, event.Source.UserID, "video", userSetting.Password, userSetting.ExpirationTime, byte)
+				data, err := createLineMedia(ctx, event.Source.UserID, "video", userSetting.Password, userSetting.ExpirationTime, byte)
 				if err != nil {
 					if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage("發生未知錯誤∑(✘Д✘๑ )")).Do(); err != nil {
 						return
@@ -284,7 +340,7 @@ func LineCallback(g *gin.Context) {
 					return
 				}
 
-				if _, err = lineClient.ReplyMessage(event.ReplyToken, linebot.NewTextMessage(domain+data.ShortUrl)).Do(); err != nil {
+				if _, err = lineClient.ReplyMessage(event.ReplyToken, linkMessages("media", data.ShortUrl)...).Do(); err != nil {
 					return
 				}
 
@@ -297,3 +353,33 @@ func LineCallback(g *gin.Context) {
 		}
 	}
 }
+
+// createLineMedia stores content sent to the bot with the media settings of
+// the LINE user, whose password "none" stands for no password.
+func createLineMedia(ctx context.Context, objectId, mediaType, password string, expirationTime int64, content []byte) (*mediastore.Media, error) {
+	if password == "none" {
+		password = ""
+	}
+	password, err := mediastore.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return createMedia(ctx, &mediastore.Media{
+		UserId:   objectId,
+		Type:     mediaType,
+		Password: password,
+	}, expirationTime, content)
+}
+
+// linkMessages answers with the public url of a new code and, when api.host is
+// configured, a qr code image LINE can fetch from the qr routes.
+func linkMessages(group, shortUrl string) []linebot.SendingMessage {
+	messages := []linebot.SendingMessage{linebot.NewTextMessage(domain + shortUrl)}
+	if apiHost != "" {
+		qr := apiHost + "/api/" + group + "/" + shortUrl + "/qr"
+		messages = append(messages, linebot.NewImageMessage(qr+"?size=1024", qr+"?size=240"))
+	}
+
+	return messages
+}
@@ -1,13 +1,17 @@
 package router
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"io"
 	"net/http"
+	"privaTutle/internal/claim"
+	"privaTutle/internal/mediastore"
 	"privaTutle/model"
 	"privaTutle/pkg/auth"
-	"privaTutle/service/media"
 	"strconv"
+	"strings"
 	"time"
 
 	fileHelper "privaTutle/pkg/file_helper"
@@ -15,12 +19,22 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
+	"github.com/spf13/viper"
 )
 
-func NewMediaRouter(group *gin.RouterGroup) {
+func NewMediaRouter(group *gin.RouterGroup, cnf *viper.Viper) {
+	domain = cnf.GetString("frontend.host")
+	apiHost = cnf.GetString("api.host")
 	group.POST("/image", UploadImage)
 	group.POST("/video", UploadVideo)
+	group.POST("/upload", CreateUpload)
+	group.HEAD("/upload/:id", UploadOffset)
+	group.PATCH("/upload/:id", UploadChunk)
+	group.DELETE("/upload/:id", DeleteUpload)
 	group.GET("/:short", GetMedia)
+	group.GET("/:short/qr", MediaQRCode)
+	group.GET("/:short/content", MediaContent)
+	group.GET("/:short/download", DownloadMedia)
 }
 
 type UploadMediaInfo struct {
@@ -37,6 +51,7 @@ type UploadMediaInfo struct {
 // @Param  expirationTime  formData  string  true  "有效時間"
 // @Param  password  formData  string  false  "瀏覽密碼"
 // @Success 200
+// @Header 200 {string} X-Claim-Token "claim token, only for uploads without Authorization"
 // @Router /api/media/image [post]
 func UploadImage(g *gin.Context) {
 	token := g.Request.Header.Get("Authorization")
@@ -103,24 +118,64 @@ func UploadImage(g *gin.Context) {
 		return
 	}
 
-	password := info.Password
-	if password == "" {
-		password = "none"
+	password, err := mediastore.HashPassword(info.Password)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	data, err := media.MediaService.CreateMedia(ctx, objectId, "image", password, info.ExpirationTime, buf.Bytes())
+	data, err := createMedia(ctx, &mediastore.Media{
+		UserId:   objectId,
+		Type:     "image",
+		Password: password,
+	}, info.ExpirationTime, buf.Bytes())
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	if objectId == "" {
+		token, err := claim.ClaimService.NewClaim(ctx, claim.KindMedia, data.ShortUrl)
+		if err != nil {
+			httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+			return
+		}
+		g.Header(claimTokenHeader, token)
+	}
+
+	response, err := storedMediaResponse(data)
 	if err != nil {
-		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
 		return
 	}
 
-	httpHelper.SendResponse(g, data)
+	httpHelper.SendResponse(g, response)
+}
+
+// createMedia stores content that is already in memory, such as downscaled
+// images, as new media described by info.
+func createMedia(ctx context.Context, info *mediastore.Media, expirationTime int64, content []byte) (*mediastore.Media, error) {
+	info.ContentType = http.DetectContentType(content)
+	object, size, err := mediastore.MediaStoreService.Put(ctx, "media/", info.ContentType, bytes.NewReader(content), mediastore.MediaStoreService.MaxSize())
+	if err != nil {
+		return nil, err
+	}
+	info.Object, info.Size = object, size
+
+	data, err := mediastore.MediaStoreService.CreateMedia(ctx, info, expirationTime)
+	if err != nil {
+		mediastore.MediaStoreService.DeleteObject(context.Background(), object)
+		return nil, err
+	}
+
+	return data, nil
 }
 
 // @Summary UploadVideo
+// @Description The video is streamed into storage while it is received. For large files use the resumable upload.
 // @Tags Media
 // @Accept  mpfd
 // @produce json
@@ -129,6 +184,7 @@ func UploadImage(g *gin.Context) {
 // @Param  expirationTime  formData  string  true  "有效時間"
 // @Param  password  formData  string  false  "瀏覽密碼"
 // @Success 200
+// @Header 200 {string} X-Claim-Token "claim token, only for uploads without Authorization"
 // @Router /api/media/video [post]
 func UploadVideo(g *gin.Context) {
 	token := g.Request.Header.Get("Authorization")
@@ -146,40 +202,80 @@ func UploadVideo(g *gin.Context) {
 		}
 	}
 
-	file, err := g.FormFile("video")
+	reader, err := g.Request.MultipartReader()
 	if err != nil {
-		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
 		return
 	}
 
-	var buf []byte
-	if file != nil {
-		buf, err = fileHelper.ReadFile(file)
-		if err != nil {
-			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
-			return
+	// the video is written before the other fields are known, so it is
+	// removed again unless the media gets created
+	var object, contentType string
+	var size int64
+	created := false
+	defer func() {
+		if object != "" && !created {
+			mediastore.MediaStoreService.DeleteObject(context.Background(), object)
 		}
-		if len(buf) == 0 {
-			return
+	}()
+
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
 		}
-		if !fileHelper.IsVideo(http.DetectContentType(buf)) {
-			httpHelper.SendError(g, http.StatusBadRequest, "ErrInvalidFileType")
+		if err != nil {
+			httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
 			return
 		}
-	} else {
+
+		switch name := part.FormName(); name {
+		case "video":
+			if object != "" {
+				httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+				return
+			}
+			body := bufio.NewReaderSize(part, 512)
+			head, _ := body.Peek(512)
+			if len(head) == 0 {
+				httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+				return
+			}
+			contentType = http.DetectContentType(head)
+			if !fileHelper.IsVideo(contentType) {
+				httpHelper.SendError(g, http.StatusBadRequest, "ErrInvalidFileType")
+				return
+			}
+
+			object, size, err = mediastore.MediaStoreService.Put(g.Request.Context(), "media/", contentType, body, mediastore.MediaStoreService.MaxSize())
+			if err != nil {
+				httpHelper.SendError(g, mediaStatus(err), err.Error())
+				return
+			}
+		case "expirationTime", "password":
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			if err != nil {
+				httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+				return
+			}
+			fields[name] = string(value)
+		}
+	}
+	if object == "" {
 		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
 		return
 	}
 
-	expirationTime, err := strconv.ParseInt(g.PostForm("expirationTime"), 10, 64)
+	expirationTime, err := strconv.ParseInt(fields["expirationTime"], 10, 64)
 	if err != nil {
-		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
 		return
 	}
 
 	info := &UploadMediaInfo{
 		ExpirationTime: expirationTime,
-		Password:       g.PostForm("password"),
+		Password:       fields["password"],
 	}
 
 	validate := validator.New()
@@ -189,24 +285,49 @@ func UploadVideo(g *gin.Context) {
 		return
 	}
 
-	password := info.Password
-	if password == "" {
-		password = "none"
+	password, err := mediastore.HashPassword(info.Password)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	data, err := media.MediaService.CreateMedia(ctx, objectId, "video", password, info.ExpirationTime, buf)
+	data, err := mediastore.MediaStoreService.CreateMedia(ctx, &mediastore.Media{
+		UserId:      objectId,
+		Type:        "video",
+		Object:      object,
+		ContentType: contentType,
+		Size:        size,
+		Password:    password,
+	}, info.ExpirationTime)
 	if err != nil {
-		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
 		return
 	}
+	created = true
 
-	httpHelper.SendResponse(g, data)
+	if objectId == "" {
+		token, err := claim.ClaimService.NewClaim(ctx, claim.KindMedia, data.ShortUrl)
+		if err != nil {
+			httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+			return
+		}
+		g.Header(claimTokenHeader, token)
+	}
+
+	response, err := storedMediaResponse(data)
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, response)
 }
 
 // @Summary GetMedia
+// @Description url is a signed download url that is valid until urlExpireTime, at most until the media expires.
 // @Tags Media
 // @Accept  json
 // @produce json
@@ -237,11 +358,175 @@ func GetMedia(g *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	data, err := media.MediaService.TranslateMedia(ctx, shortUrl, password, objectId)
+	data, err := mediastore.MediaStoreService.TranslateMedia(ctx, shortUrl, password, objectId)
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	response, err := storedMediaResponse(data)
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, response)
+}
+
+// @Summary MediaQRCode
+// @Tags Media
+// @produce png,image/svg+xml
+// @Param  short  path  string  true  "short"
+// @Param  format  query  string  false  "png or svg, default png"
+// @Param  size  query  int  false  "width in pixels, 64 to 2048, default 256"
+// @Param  margin  query  int  false  "quiet zone in modules, 0 to 16, default 4"
+// @Param  level  query  string  false  "error correction L, M, Q or H, default M"
+// @Success 200
+// @Router /api/media/{short}/qr [get]
+func MediaQRCode(g *gin.Context) {
+	sendQRCode(g, g.Param("short"))
+}
+
+// @Summary MediaContent
+// @Description Serves the content of media, the password and expiration are checked like for GetMedia. Supports Range, If-Range and conditional requests.
+// @Tags Media
+// @produce octet-stream
+// @Param  Authorization  header  string  false  "Authorization"
+// @Param  short  path  string  true  "short"
+// @Param  password  query  string  false  "password"
+// @Success 200
+// @Router /api/media/{short}/content [get]
+func MediaContent(g *gin.Context) {
+	shortUrl := g.Param("short")
+	password := g.Query("password")
+
+	token := g.Request.Header.Get("Authorization")
+	var objectId string
+	var err error
+	if token != "" {
+		objectId, err = auth.AuthJWT(token)
+		if err != nil {
+			if err == auth.ErrVaild {
+				httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+				return
+			}
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	data, err := mediastore.MediaStoreService.TranslateMedia(ctx, shortUrl, password, objectId)
+	cancel()
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	sendMediaContent(g, data)
+}
+
+// @Summary DownloadMedia
+// @Description Serves media through the signed url returned by GetMedia, for storage backends that cannot sign urls themselves. Supports Range, If-Range and conditional requests.
+// @Tags Media
+// @produce octet-stream
+// @Param  short  path  string  true  "short"
+// @Param  expires  query  string  true  "expires"
+// @Param  signature  query  string  true  "signature"
+// @Success 200
+// @Router /api/media/{short}/download [get]
+func DownloadMedia(g *gin.Context) {
+	shortUrl := g.Param("short")
+
+	err := mediastore.MediaStoreService.VerifySignature(shortUrl, g.Query("expires"), g.Query("signature"))
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	data, err := mediastore.MediaStoreService.GetMedia(ctx, shortUrl)
+	cancel()
 	if err != nil {
-		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
 		return
 	}
+	// the url outlives neither the media nor its deletion
+	if data.Status != mediastore.StatusActive {
+		httpHelper.SendError(g, http.StatusNotFound, model.ErrMediaNotFound.Error())
+		return
+	}
+	if !time.Now().Before(data.ExpireTime) {
+		httpHelper.SendError(g, http.StatusGone, model.ErrMediaExpired.Error())
+		return
+	}
+
+	sendMediaContent(g, data)
+}
 
-	httpHelper.SendResponse(g, data)
+// sendMediaContent streams the content of data. Range and If-Range requests
+// get partial content, so players can seek without loading the whole video.
+func sendMediaContent(g *gin.Context, data *mediastore.Media) {
+	// the download lasts as long as the client keeps reading
+	content, err := mediastore.MediaStoreService.OpenContent(g.Request.Context(), data, rangeStart(g.Request))
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+	defer content.Close()
+
+	g.Header("Content-Type", data.ContentType)
+	g.Header("ETag", data.ETag())
+	g.Header("Cache-Control", "private, no-cache")
+	http.ServeContent(g.Writer, g.Request, "", data.CreateTime, content)
+}
+
+// rangeStart returns where the single range of a Range request starts, so
+// the content is opened there, or 0 for anything else.
+func rangeStart(r *http.Request) int64 {
+	byteRange := r.Header.Get("Range")
+	if !strings.HasPrefix(byteRange, "bytes=") || strings.Contains(byteRange, ",") {
+		return 0
+	}
+	start, _, _ := strings.Cut(strings.TrimPrefix(byteRange, "bytes="), "-")
+	offset, err := strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return offset
+}
+
+// storedMediaResponse adds a short-lived signed url of the content to
+// media.
+func storedMediaResponse(data *mediastore.Media) (gin.H, error) {
+	signed, expires, err := mediastore.MediaStoreService.SignedURL(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"data":          data,
+		"url":           signed,
+		"urlExpireTime": expires,
+	}, nil
+}
+
+func mediaStatus(err error) int {
+	switch err {
+	case model.ErrMediaNotFound, model.ErrUploadNotFound:
+		return http.StatusNotFound
+	case model.ErrMediaExpired:
+		return http.StatusGone
+	case model.ErrMediaPassword, model.ErrMediaSignature:
+		return http.StatusForbidden
+	case model.ErrMediaTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case model.ErrUploadOffset:
+		return http.StatusConflict
+	case model.ErrInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
 }
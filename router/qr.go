@@ -0,0 +1,78 @@
+package router
+
+import (
+	"net/http"
+	"privaTutle/internal/customdomain"
+	"privaTutle/internal/qrcode"
+	"privaTutle/model"
+	"strconv"
+
+	httpHelper "privaTutle/pkg/http_helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+)
+
+type QRCodeInfo struct {
+	Format string `validate:"oneof=png svg"`
+	Size   int    `validate:"gte=64,lte=2048"`
+	Margin int    `validate:"gte=0,lte=16"`
+	Level  string `validate:"oneof=L M Q H"`
+}
+
+// sendQRCode renders the public url of a short code. The code itself is not
+// looked up, the image reveals nothing the url does not.
+func sendQRCode(g *gin.Context, shortUrl string) {
+	size, err := strconv.Atoi(g.DefaultQuery("size", "256"))
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	margin, err := strconv.Atoi(g.DefaultQuery("margin", "4"))
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	info := QRCodeInfo{
+		Format: g.DefaultQuery("format", "png"),
+		Size:   size,
+		Margin: margin,
+		Level:  g.DefaultQuery("level", "M"),
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	opts := qrcode.Options{Size: info.Size, Margin: info.Margin, Level: info.Level}
+	var data []byte
+	var contentType string
+	switch info.Format {
+	case "svg":
+		data, err = qrcode.SVG(publicUrl(shortUrl), opts)
+		contentType = "image/svg+xml"
+	default:
+		data, err = qrcode.PNG(publicUrl(shortUrl), opts)
+		contentType = "image/png"
+	}
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	g.Header("Cache-Control", "public, max-age=86400")
+	g.Data(http.StatusOK, contentType, data)
+}
+
+// publicUrl is the address a short code is served at, on its custom domain
+// when it has one.
+func publicUrl(shortUrl string) string {
+	code, host := customdomain.SplitKey(shortUrl)
+	if host != "" {
+		return "https://" + host + "/" + code
+	}
+	return domain + shortUrl
+}
@@ -0,0 +1,111 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"privaTutle/internal/customdomain"
+	"privaTutle/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+var redirectStatus int
+
+func NewRedirectRouter(group *gin.RouterGroup, cnf *viper.Viper) {
+	redirectStatus = cnf.GetInt("short.redirectStatus")
+	switch redirectStatus {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect:
+	default:
+		redirectStatus = http.StatusFound
+	}
+	group.GET("/:short", Redirect)
+}
+
+const statusPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>%d %s</title></head>
+<body><h1>%d %s</h1><p>%s</p></body>
+</html>`
+
+func sendStatusPage(g *gin.Context, code int, message string) {
+	page := fmt.Sprintf(statusPage, code, http.StatusText(code), code, http.StatusText(code), message)
+	g.Data(code, "text/html; charset=utf-8", []byte(page))
+}
+
+const passwordPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Password required</title></head>
+<body>
+<h1>Password required</h1>
+<p>%s</p>
+<form method="get"><input type="password" name="password" autofocus><button type="submit">Open</button></form>
+</body>
+</html>`
+
+func sendPasswordPage(g *gin.Context, message string) {
+	page := fmt.Sprintf(passwordPage, message)
+	g.Data(http.StatusUnauthorized, "text/html; charset=utf-8", []byte(page))
+}
+
+// @Summary Redirect
+// @Tags Short
+// @produce html
+// @Param  short  path  string  true  "short"
+// @Param  password  query  string  false  "password"
+// @Param  X-Short-Password  header  string  false  "password"
+// @Success 301
+// @Success 302
+// @Success 307
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 410
+// @Router /{short} [get]
+func Redirect(g *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// on a custom domain only the codes of that domain are served
+	d, err := customdomain.DomainService.Lookup(ctx, g.Request.Host)
+	if err != nil {
+		sendStatusPage(g, http.StatusInternalServerError, "Something went wrong, please try again later.")
+		return
+	}
+	shortUrl := g.Param("short")
+	if d != nil {
+		shortUrl = customdomain.Key(shortUrl, d.Host)
+	}
+
+	// link previews and crawlers would use up the clicks of limited links
+	leadUrl, variant, err := translateShort(ctx, shortUrl, shortPassword(g), visitor(g), isBot(g))
+	if err != nil {
+		switch err {
+		case model.ErrShortDeleted:
+			sendStatusPage(g, http.StatusGone, "This link has been removed.")
+		case model.ErrShortExpired:
+			sendStatusPage(g, http.StatusGone, "This link has expired.")
+		case model.ErrShortExhausted:
+			sendStatusPage(g, http.StatusGone, "This link has reached its click limit.")
+		case model.ErrShortPreview:
+			sendStatusPage(g, http.StatusForbidden, "This link can only be opened a limited number of times, open it in a browser.")
+		case model.ErrShortPasswordRequired:
+			sendPasswordPage(g, "This link is protected by a password.")
+		case model.ErrShortPassword:
+			sendPasswordPage(g, "The password is incorrect.")
+		case model.ErrShortNotActive:
+			sendStatusPage(g, http.StatusNotFound, "This link is not active yet.")
+		case model.ErrInternal:
+			sendStatusPage(g, http.StatusInternalServerError, "Something went wrong, please try again later.")
+		default:
+			sendStatusPage(g, http.StatusNotFound, "This link does not exist.")
+		}
+		return
+	}
+
+	recordClick(g, shortUrl, variant)
+
+	g.Redirect(redirectStatus, leadUrl)
+}
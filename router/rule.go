@@ -0,0 +1,50 @@
+package router
+
+import (
+	"context"
+	"privaTutle/internal/canonical"
+	"privaTutle/internal/link"
+	"privaTutle/internal/policy"
+)
+
+type RuleInfo struct {
+	Platforms []string      `validate:"max=6,dive,oneof=ios android windows mac linux other"`
+	Languages []string      `validate:"max=10,dive,min=2,max=10"`
+	Variants  []VariantInfo `validate:"required,min=1,max=10,dive"`
+}
+
+type VariantInfo struct {
+	Name    string `validate:"required,max=20"`
+	LeadUrl string `validate:"required,url"`
+	Weight  int    `validate:"gte=0,lte=1000"`
+}
+
+// linkRules canonicalizes every variant destination, checks it against the url
+// policy and converts the rules for the link service.
+func linkRules(ctx context.Context, infos []RuleInfo) ([]link.Rule, error) {
+	rules := make([]link.Rule, 0, len(infos))
+	for _, info := range infos {
+		rule := link.Rule{
+			Platforms: info.Platforms,
+			Languages: info.Languages,
+		}
+		for _, variant := range info.Variants {
+			leadUrl, err := canonical.URL(variant.LeadUrl, stripTracking)
+			if err != nil {
+				return nil, err
+			}
+			err = policy.PolicyService.Check(ctx, leadUrl)
+			if err != nil {
+				return nil, err
+			}
+			rule.Variants = append(rule.Variants, link.Variant{
+				Name:    variant.Name,
+				LeadUrl: leadUrl,
+				Weight:  variant.Weight,
+			})
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -2,26 +2,82 @@ package router
 
 import (
 	"net/http"
+	"privaTutle/internal/analytics"
+	"privaTutle/internal/canonical"
+	"privaTutle/internal/claim"
+	"privaTutle/internal/codegen"
+	"privaTutle/internal/customdomain"
+	"privaTutle/internal/link"
+	"privaTutle/internal/policy"
 	"privaTutle/model"
 	"privaTutle/pkg/auth"
-	"privaTutle/pkg/hash"
 	httpHelper "privaTutle/pkg/http_helper"
 	"privaTutle/service/short"
 
 	"context"
+	"encoding/csv"
+	"io"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func NewShortRouter(group *gin.RouterGroup) {
+func NewShortRouter(group *gin.RouterGroup, cnf *viper.Viper) {
+	domain = cnf.GetString("frontend.host")
+	stripTracking = cnf.GetBool("short.canonical.stripTracking")
 	group.POST("", Short)
+	group.POST("/batch", ShortBatch)
 	group.GET("/:short", GetShort)
+	group.GET("/:short/qr", ShortQRCode)
 }
 
 type ShortInfo struct {
-	LeadUrl string `validate:"required,url"`
+	LeadUrl     string    `validate:"required,url"`
+	Alias       string    `validate:"omitempty,min=3,max=32,alias"`
+	ActivatesAt time.Time `validate:"omitempty"`
+	ExpiresAt   time.Time `validate:"omitempty,gt,gtfield=ActivatesAt"`
+	Password    string    `validate:"max=20"`
+	MaxClicks   int64     `validate:"gte=0,lte=1000000"`
+	Tags        []string  `validate:"max=10,dive,min=1,max=20"`
+	Folder      string    `validate:"max=30"`
+	// Domain is a verified custom domain of the user to serve the link from.
+	Domain string `validate:"omitempty,fqdn"`
+	// ForceNew creates a new link even when the user already has a plain
+	// link to the same destination.
+	ForceNew bool
+	// Rules send matching visitors elsewhere than LeadUrl, the first
+	// matching rule wins.
+	Rules []RuleInfo `validate:"max=10,dive"`
+}
+
+// stripTracking removes tracking parameters from destinations before they
+// are stored and compared.
+var stripTracking bool
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedAlias holds the codes that would shadow routes served from the root path.
+var reservedAlias = map[string]bool{
+	"api":     true,
+	"swagger": true,
+	"admin":   true,
+	"static":  true,
+}
+
+func validateAlias(fl validator.FieldLevel) bool {
+	alias := fl.Field().String()
+	return aliasPattern.MatchString(alias) && !reservedAlias[strings.ToLower(alias)]
+}
+
+func newShortValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterValidation("alias", validateAlias)
+	return validate
 }
 
 // @Summary Short
@@ -51,49 +107,459 @@ func Short(g *gin.Context) {
 	info := ShortInfo{}
 	g.BindJSON(&info)
 
-	validate := validator.New()
-	err = validate.Struct(info)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shortUrl, existing, err := createShort(ctx, objectId, info)
 	if err != nil {
-		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		httpHelper.SendError(g, createShortStatus(err), err.Error())
 		return
 	}
 
-	shortUrl := hash.StringHash(info.LeadUrl)
+	response := gin.H{
+		"shortUrl": shortUrl,
+		"url":      publicUrl(shortUrl),
+		"existing": existing,
+	}
+	if objectId == "" {
+		response["claimToken"], err = claim.ClaimService.NewClaim(ctx, claim.KindShort, shortUrl)
+		if err != nil {
+			httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	httpHelper.SendResponse(g, response)
+}
 
-	data, err := short.ShortService.CreateShort(ctx, objectId, shortUrl, info.LeadUrl)
-	if err != nil {
-		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+const maxBatchSize = 500
+
+type ShortBatchResult struct {
+	Index    int    `json:"index"`
+	ShortUrl string `json:"shortUrl,omitempty"`
+	// Existing is set when the user already had a link to the destination.
+	Existing bool `json:"existing,omitempty"`
+	// ClaimToken is only returned for links created without Authorization.
+	ClaimToken string `json:"claimToken,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// @Summary ShortBatch
+// @Tags Short
+// @Accept  json,mpfd
+// @produce json
+// @Param  Authorization  header  string  false  "Authorization"
+// @Param  body  body  []ShortInfo  false  "body"
+// @Param  file  formData  file  false  "csv with a header row: leadUrl,alias,activatesAt,expiresAt,password"
+// @Success 200
+// @Router /api/short/batch [post]
+func ShortBatch(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	var objectId string
+	var err error
+	if token != "" {
+		objectId, err = auth.AuthJWT(token)
+		if err != nil {
+			if err == auth.ErrVaild {
+				httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+				return
+			}
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+	}
+
+	var infos []ShortInfo
+	var rowErrs map[int]error
+	if strings.HasPrefix(g.ContentType(), "multipart/form-data") {
+		file, err := g.FormFile("file")
+		if err != nil {
+			httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+		defer f.Close()
+
+		infos, rowErrs, err = parseShortCSV(f)
+		if err != nil {
+			httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else if err = g.BindJSON(&infos); err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
 		return
 	}
 
+	if len(infos) == 0 || len(infos) > maxBatchSize {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	results := make([]ShortBatchResult, len(infos))
+	for i, info := range infos {
+		results[i].Index = i
+		if rowErr, ok := rowErrs[i]; ok {
+			results[i].Error = rowErr.Error()
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		results[i].ShortUrl, results[i].Existing, err = createShort(ctx, objectId, info)
+		if err == nil && objectId == "" {
+			results[i].ClaimToken, err = claim.ClaimService.NewClaim(ctx, claim.KindShort, results[i].ShortUrl)
+		}
+		cancel()
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
 	httpHelper.SendResponse(g, gin.H{
-		"shortUrl": data.ShortUrl,
+		"data": results,
 	})
 }
 
+// parseShortCSV reads batch entries from a csv file whose first row names the
+// columns. Rows that cannot be turned into a ShortInfo are reported in rowErrs
+// by their index instead of failing the whole file.
+func parseShortCSV(r io.Reader) ([]ShortInfo, map[int]error, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, model.ErrParameter
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["leadurl"]; !ok {
+		return nil, nil, model.ErrParameter
+	}
+
+	var infos []ShortInfo
+	rowErrs := map[int]error{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, model.ErrParameter
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		info := ShortInfo{
+			LeadUrl:  field("leadurl"),
+			Alias:    field("alias"),
+			Password: field("password"),
+		}
+		if v := field("activatesat"); v != "" {
+			if info.ActivatesAt, err = time.Parse(time.RFC3339, v); err != nil {
+				rowErrs[len(infos)] = model.ErrParameter
+			}
+		}
+		if v := field("expiresat"); v != "" {
+			if info.ExpiresAt, err = time.Parse(time.RFC3339, v); err != nil {
+				rowErrs[len(infos)] = model.ErrParameter
+			}
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, rowErrs, nil
+}
+
+// createShort validates info against the url policy and stores a new short
+// code for it, either the requested alias or one from the configured generator.
+// Unless info.ForceNew is set, a user shortening a destination again without
+// any link settings gets the plain link they already have, which is reported
+// by existing.
+func createShort(ctx context.Context, objectId string, info ShortInfo) (shortUrl string, existing bool, err error) {
+	validate := newShortValidator()
+	err = validate.Struct(info)
+	if err != nil {
+		return "", false, model.ErrParameter
+	}
+
+	info.LeadUrl, err = canonical.URL(info.LeadUrl, stripTracking)
+	if err != nil {
+		return "", false, err
+	}
+	err = policy.PolicyService.Check(ctx, info.LeadUrl)
+	if err != nil {
+		return "", false, err
+	}
+	rules, err := linkRules(ctx, info.Rules)
+	if err != nil {
+		return "", false, err
+	}
+
+	var host string
+	if info.Domain != "" {
+		d, err := customdomain.DomainService.Lookup(ctx, info.Domain)
+		if err != nil {
+			return "", false, err
+		}
+		if d == nil || objectId == "" || d.UserId != objectId {
+			return "", false, model.ErrDomainNotFound
+		}
+		host = d.Host
+	}
+
+	if objectId != "" && !info.ForceNew && info.plain() {
+		data, err := link.LinkService.FindDuplicateLink(ctx, objectId, host, info.LeadUrl)
+		if err == nil {
+			return data.ShortUrl, true, nil
+		}
+		if err != model.ErrShortNotFound {
+			return "", false, err
+		}
+	}
+	// the link is inserted before anything else, so its unique code index
+	// decides between concurrent requests for the same code. Codes only have
+	// to be unique on their own domain.
+	var created *link.Link
+	take := func(ctx context.Context, code string) (bool, error) {
+		shortUrl := customdomain.Key(code, host)
+		exists, err := shortExists(ctx, shortUrl)
+		if err != nil || exists {
+			return exists, err
+		}
+
+		created, err = link.LinkService.CreateLink(ctx, &link.Link{
+			ShortUrl:    shortUrl,
+			Domain:      host,
+			UserId:      objectId,
+			LeadUrl:     info.LeadUrl,
+			ActivatesAt: info.ActivatesAt,
+			ExpiresAt:   info.ExpiresAt,
+			Password:    info.Password,
+			MaxClicks:   info.MaxClicks,
+			Tags:        info.Tags,
+			Folder:      info.Folder,
+			Rules:       rules,
+		})
+		if err == model.ErrAliasTaken {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if info.Alias != "" {
+		taken, err := take(ctx, info.Alias)
+		if err != nil {
+			return "", false, err
+		}
+		if taken {
+			return "", false, model.ErrAliasTaken
+		}
+	} else {
+		_, err = codegen.CodeService.NewCode(ctx, info.LeadUrl, take)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	data, err := short.ShortService.CreateShort(ctx, objectId, created.ShortUrl, info.LeadUrl)
+	if err != nil {
+		link.LinkService.DeleteLink(ctx, created.Id)
+		return "", false, err
+	}
+
+	return data.ShortUrl, false, nil
+}
+
+// plain reports whether info asks for a link without an alias or any of the
+// settings that make links to the same destination differ.
+func (info *ShortInfo) plain() bool {
+	return info.Alias == "" && info.Password == "" && info.MaxClicks == 0 &&
+		info.ActivatesAt.IsZero() && info.ExpiresAt.IsZero() && len(info.Rules) == 0
+}
+
+func createShortStatus(err error) int {
+	switch err {
+	case model.ErrAliasTaken:
+		return http.StatusConflict
+	case model.ErrDomainNotFound:
+		return http.StatusNotFound
+	case model.ErrUrlScheme, model.ErrUrlPrivate, model.ErrUrlLoop, model.ErrUrlDenied:
+		return http.StatusUnprocessableEntity
+	case model.ErrInternal, model.ErrShortCodeExhausted:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
 // @Summary GetShort
 // @Tags Short
 // @Accept  json
 // @produce json
 // @Param  short  path  string  true  "short"
+// @Param  domain  query  string  false  "custom domain of the link"
+// @Param  password  query  string  false  "password"
+// @Param  X-Short-Password  header  string  false  "password"
 // @Success 200
 // @Router /api/short/{short} [get]
 func GetShort(g *gin.Context) {
-	shortUrl := g.Param("short")
-
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	data, err := short.ShortService.TranslateShort(ctx, shortUrl)
+	shortUrl := g.Param("short")
+	// like on the redirect, only the verified owner's codes of a domain are served
+	if host := g.Query("domain"); host != "" {
+		d, err := customdomain.DomainService.Lookup(ctx, host)
+		if err != nil {
+			httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if d == nil {
+			httpHelper.SendError(g, http.StatusBadRequest, model.ErrShortNotFound.Error())
+			return
+		}
+		shortUrl = customdomain.Key(shortUrl, d.Host)
+	}
+
+	leadUrl, variant, err := translateShort(ctx, shortUrl, shortPassword(g), visitor(g), false)
 	if err != nil {
+		if err == model.ErrShortPasswordRequired || err == model.ErrShortPassword {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	recordClick(g, shortUrl, variant)
+
 	httpHelper.SendResponse(g, gin.H{
-		"leadUrl": data.LeadUrl,
+		"leadUrl": leadUrl,
+		"variant": variant,
 	})
 }
+
+// recordClick stores the resolution in the background so the visitor is not
+// kept waiting on the analytics write.
+func recordClick(g *gin.Context, shortUrl, variant string) {
+	referrer, userAgent, ip := g.Request.Referer(), g.Request.UserAgent(), g.ClientIP()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		analytics.AnalyticsService.RecordClick(ctx, shortUrl, variant, referrer, userAgent, ip)
+	}()
+}
+
+// shortExists reports whether a code is used by a short from before the link
+// collection. Codes with a link are refused by CreateLink itself.
+func shortExists(ctx context.Context, shortUrl string) (bool, error) {
+	data, err := short.ShortService.TranslateShort(ctx, shortUrl)
+	if err != nil {
+		if shortNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return data != nil, nil
+}
+
+// shortNotFound tells an unknown code from a failed lookup of the short
+// service. It lives in its own module, so its error is matched by name.
+func shortNotFound(err error) bool {
+	return err == mongo.ErrNoDocuments || err.Error() == model.ErrShortNotFound.Error()
+}
+
+// @Summary ShortQRCode
+// @Tags Short
+// @produce png,image/svg+xml
+// @Param  short  path  string  true  "short"
+// @Param  format  query  string  false  "png or svg, default png"
+// @Param  size  query  int  false  "width in pixels, 64 to 2048, default 256"
+// @Param  margin  query  int  false  "quiet zone in modules, 0 to 16, default 4"
+// @Param  level  query  string  false  "error correction L, M, Q or H, default M"
+// @Success 200
+// @Router /api/short/{short}/qr [get]
+func ShortQRCode(g *gin.Context) {
+	sendQRCode(g, g.Param("short"))
+}
+
+// shortPassword reads the password of a protected link from the query or the
+// X-Short-Password header.
+func shortPassword(g *gin.Context) string {
+	if password := g.Query("password"); password != "" {
+		return password
+	}
+	return g.GetHeader("X-Short-Password")
+}
+
+// isBot reports whether the requester is a crawler or link preview rather than
+// a person. They are not shown the destination of click-limited links.
+func isBot(g *gin.Context) bool {
+	device, _ := analytics.ClassifyUserAgent(g.Request.UserAgent())
+	return device == analytics.DeviceBot
+}
+
+// visitor describes the requester for the destination rules of a link.
+func visitor(g *gin.Context) *link.Visitor {
+	return link.NewVisitor(g.Request.UserAgent(), g.GetHeader("Accept-Language"))
+}
+
+// translateShort resolves a short code to the destination for visitor,
+// refusing codes that were deleted through DeleteShort, are outside their
+// active window, are protected by another password or have used up their
+// clicks. Every resolution of a click-limited link uses up a click, previews
+// are refused with model.ErrShortPreview instead of being shown the
+// destination. variant names the rule variant that was picked, if any.
+func translateShort(ctx context.Context, shortUrl, password string, visitor *link.Visitor, preview bool) (leadUrl, variant string, err error) {
+	l, err := link.LinkService.GetLink(ctx, shortUrl)
+	if err != nil && err != model.ErrShortNotFound {
+		return "", "", err
+	}
+	if l != nil {
+		if l.Status == link.StatusDelete || l.Status == link.StatusPurge {
+			return "", "", model.ErrShortDeleted
+		}
+		if err := l.Available(time.Now()); err != nil {
+			return "", "", err
+		}
+		if err := l.CheckPassword(password); err != nil {
+			return "", "", err
+		}
+		if l.ClicksLeft() == 0 {
+			return "", "", model.ErrShortExhausted
+		}
+		if l.MaxClicks > 0 {
+			if preview {
+				return "", "", model.ErrShortPreview
+			}
+			if _, err := link.LinkService.ConsumeLinkClick(ctx, shortUrl); err != nil {
+				return "", "", err
+			}
+		}
+		// the link keeps the current destination once it has been edited
+		leadUrl, variant = l.Destination(visitor)
+		return leadUrl, variant, nil
+	}
+
+	data, err := short.ShortService.TranslateShort(ctx, shortUrl)
+	if err != nil {
+		return "", "", err
+	}
+
+	return data.LeadUrl, "", nil
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"privaTutle/internal/sweeper"
+
+	httpHelper "privaTutle/pkg/http_helper"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewStatusRouter(group *gin.RouterGroup) {
+	group.GET("/sweeper", SweeperStats)
+}
+
+// @Summary SweeperStats
+// @Description Counts of the expired media the sweeper of this instance removed since it started.
+// @Tags Status
+// @produce json
+// @Success 200
+// @Router /api/status/sweeper [get]
+func SweeperStats(g *gin.Context) {
+	httpHelper.SendResponse(g, sweeper.SweeperService.Stats())
+}
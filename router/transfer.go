@@ -0,0 +1,296 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"privaTutle/internal/analytics"
+	"privaTutle/internal/customdomain"
+	"privaTutle/internal/link"
+	"privaTutle/internal/transfer"
+	"privaTutle/model"
+	"privaTutle/pkg/auth"
+	"privaTutle/service/short"
+	"strings"
+	"time"
+
+	httpHelper "privaTutle/pkg/http_helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+)
+
+const (
+	maxImportSize  = 1000
+	maxImportBytes = 10 << 20
+)
+
+type ShortExportInfo struct {
+	Format string `validate:"oneof=csv json"`
+	ShortFilterInfo
+}
+
+// @Summary ExportShort
+// @Tags User
+// @produce json,text/csv
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  format  query  string  false  "csv or json, default json"
+// @Param  tag  query  string  false  "tag"
+// @Param  folder  query  string  false  "folder"
+// @Param  q  query  string  false  "substring of name, code or destination"
+// @Param  status  query  string  false  "active, scheduled, expired, exhausted or delete"
+// @Param  health  query  string  false  "ok, broken, unknown or unchecked"
+// @Param  from  query  string  false  "created at or after, RFC3339"
+// @Param  to  query  string  false  "created before, RFC3339"
+// @Param  sort  query  string  false  "createTime, -createTime, name or -name, default -createTime"
+// @Success 200
+// @Router /api/user/short/export [get]
+func ExportShort(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	filter, err := bindShortFilter(g)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+	info := ShortExportInfo{
+		Format:          g.DefaultQuery("format", "json"),
+		ShortFilterInfo: filter,
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	err = importLegacyShorts(ctx, objectId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records := []*transfer.Record{}
+	cursor := ""
+	for {
+		data, next, err := link.LinkService.ListUserLinksAfter(ctx, objectId, info.listFilter(), cursor, 500)
+		if err != nil {
+			httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		shortUrls := make([]string, 0, len(data))
+		for _, d := range data {
+			shortUrls = append(shortUrls, d.ShortUrl)
+		}
+		clicks, err := analytics.AnalyticsService.CountClicks(ctx, shortUrls)
+		if err != nil {
+			httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, d := range data {
+			records = append(records, &transfer.Record{
+				Name:        d.Name,
+				Code:        d.ShortUrl,
+				Destination: d.LeadUrl,
+				CreateTime:  d.CreateTime,
+				Status:      d.Status,
+				Clicks:      clicks[d.ShortUrl],
+				Tags:        d.Tags,
+				Folder:      d.Folder,
+			})
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	buf := &bytes.Buffer{}
+	contentType := "application/json; charset=utf-8"
+	if info.Format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+		err = transfer.WriteCSV(buf, records)
+	} else {
+		err = json.NewEncoder(buf).Encode(records)
+	}
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	g.Header("Content-Disposition", `attachment; filename="shorts.`+info.Format+`"`)
+	g.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+type ShortImportResult struct {
+	Index    int    `json:"index"`
+	Code     string `json:"code,omitempty"`
+	ShortUrl string `json:"shortUrl,omitempty"`
+	// Conflict is set when the code was taken or not allowed, ShortUrl is
+	// the generated code the link got instead.
+	Conflict bool   `json:"conflict,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// @Summary ImportShort
+// @Description Accepts the csv or json of ExportShort and the bit.ly csv link export. Codes are kept when they are free, taken or invalid codes are reported as conflicts and imported under a generated code. Deleted links are skipped.
+// @Tags User
+// @Accept  json,mpfd,text/csv
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  file  formData  file  false  "export file"
+// @Success 200
+// @Router /api/user/short/import [post]
+func ImportShort(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	var r io.Reader = g.Request.Body
+	if strings.HasPrefix(g.ContentType(), "multipart/form-data") {
+		file, err := g.FormFile("file")
+		if err != nil {
+			httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxImportBytes+1))
+	if err != nil || len(data) > maxImportBytes {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	records, rowErrs, err := transfer.Parse(data)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(records) == 0 || len(records) > maxImportSize {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	results := make([]ShortImportResult, len(records))
+	imported, conflicts := 0, 0
+	for i, record := range records {
+		results[i].Index = i
+		results[i].Code = record.Code
+		if rowErr, ok := rowErrs[i]; ok {
+			results[i].Error = rowErr.Error()
+			continue
+		}
+		if record.Status == link.StatusDelete {
+			results[i].Error = model.ErrShortDeleted.Error()
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		results[i].ShortUrl, results[i].Conflict, err = importShort(ctx, objectId, record)
+		cancel()
+		if results[i].ShortUrl != "" {
+			imported++
+		}
+		if results[i].Conflict {
+			conflicts++
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	httpHelper.SendResponse(g, gin.H{
+		"data":      results,
+		"imported":  imported,
+		"conflicts": conflicts,
+	})
+}
+
+// importShort creates the short link of one record and carries over its name
+// and creation time. A code that is taken or not allowed as an alias, or on
+// a domain the user has not verified, is replaced by a generated one, which
+// is reported by conflict. An error after
+// the link was created is returned together with its code.
+func importShort(ctx context.Context, objectId string, record *transfer.Record) (shortUrl string, conflict bool, err error) {
+	code, host := customdomain.SplitKey(record.Code)
+	// every record becomes its own link, also when destinations repeat
+	info := ShortInfo{
+		LeadUrl:  record.Destination,
+		Alias:    code,
+		Domain:   host,
+		Tags:     record.Tags,
+		Folder:   record.Folder,
+		ForceNew: true,
+	}
+	if newShortValidator().Var(code, "min=3,max=32,alias") != nil {
+		info.Alias, conflict = "", true
+	}
+	shortUrl, _, err = createShort(ctx, objectId, info)
+	if err == model.ErrDomainNotFound || err == model.ErrDomainUnverified {
+		// the host is not the user's to serve, the code goes with it
+		info.Alias, info.Domain, conflict = "", "", true
+		shortUrl, _, err = createShort(ctx, objectId, info)
+	}
+	if err == model.ErrAliasTaken {
+		info.Alias, conflict = "", true
+		shortUrl, _, err = createShort(ctx, objectId, info)
+	}
+	if err != nil {
+		return "", conflict, err
+	}
+
+	if name := []rune(record.Name); len(name) > 0 {
+		// names are limited like in UpdateShort
+		if len(name) > 15 {
+			name = name[:15]
+		}
+		_, err = short.ShortService.UpdateShortName(ctx, objectId, shortUrl, string(name))
+		if err != nil {
+			return shortUrl, conflict, err
+		}
+		_, err = link.LinkService.UpdateLinkName(ctx, objectId, shortUrl, string(name))
+		if err != nil {
+			return shortUrl, conflict, err
+		}
+	}
+
+	if !record.CreateTime.IsZero() {
+		_, err = link.LinkService.UpdateLinkCreateTime(ctx, objectId, shortUrl, record.CreateTime)
+		if err != nil {
+			return shortUrl, conflict, err
+		}
+	}
+
+	return shortUrl, conflict, nil
+}
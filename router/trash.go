@@ -0,0 +1,181 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"privaTutle/internal/claim"
+	"privaTutle/internal/link"
+	"privaTutle/internal/mediastore"
+	"privaTutle/internal/trash"
+	"privaTutle/model"
+	"privaTutle/pkg/auth"
+	"privaTutle/service/short"
+	"strconv"
+	"time"
+
+	httpHelper "privaTutle/pkg/http_helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+)
+
+type TrashListInfo struct {
+	Kind   string `validate:"omitempty,oneof=short media"`
+	Cursor string
+	Limit  int64 `validate:"gte=1,lte=100"`
+}
+
+// @Summary TrashList
+// @Description Deleted short links and media that can still be restored, most recently deleted first.
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  kind  query  string  false  "short or media"
+// @Param  cursor  query  string  false  "next of the previous page"
+// @Param  limit  query  int64  false  "1 to 100, default 20"
+// @Success 200
+// @Router /api/user/trash [get]
+func TrashList(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	limit, err := strconv.ParseInt(g.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	info := TrashListInfo{
+		Kind:   g.Query("kind"),
+		Cursor: g.Query("cursor"),
+		Limit:  limit,
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, next, err := trash.TrashService.ListUserItems(ctx, objectId, info.Kind, info.Cursor, info.Limit)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{"data": data, "next": next})
+}
+
+// @Summary RestoreShort
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  shortId  path  string  true  "shortId"
+// @Success 200
+// @Router /api/user/short/{shortId}/restore [post]
+func RestoreShort(g *gin.Context) {
+	restore(g, trash.KindShort, func(ctx context.Context, objectId, shortId string) error {
+		owner, err := claim.ClaimService.ServiceOwner(ctx, objectId, claim.KindShort, shortId)
+		if err != nil {
+			return err
+		}
+
+		_, err = short.ShortService.UpdateShortStatus(ctx, owner, shortId, "active")
+		if err != nil {
+			return err
+		}
+
+		_, err = link.LinkService.UpdateLinkStatus(ctx, objectId, shortId, link.StatusActive)
+		if err != nil && err != model.ErrShortNotFound {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// @Summary RestoreMedia
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  shortId  path  string  true  "shortId"
+// @Success 200
+// @Router /api/user/media/{shortId}/restore [post]
+func RestoreMedia(g *gin.Context) {
+	restore(g, trash.KindMedia, func(ctx context.Context, objectId, shortId string) error {
+		_, err := mediastore.MediaStoreService.UpdateMediaStatus(ctx, objectId, shortId, mediastore.StatusActive)
+		return err
+	})
+}
+
+// restore takes an item of the given kind out of the trash of the user, as
+// long as its retention window has not passed, and reactivates it with
+// activate.
+func restore(g *gin.Context, kind string, activate func(ctx context.Context, objectId, shortId string) error) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	info := DeleteShortInfo{
+		ShortId: g.Param("shortId"),
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = trash.TrashService.GetRestorableItem(ctx, objectId, kind, info.ShortId)
+	if err != nil {
+		if err == model.ErrTrashNotFound {
+			httpHelper.SendError(g, http.StatusNotFound, err.Error())
+			return
+		}
+		if err == model.ErrTrashExpired {
+			httpHelper.SendError(g, http.StatusGone, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = activate(ctx, objectId, info.ShortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = trash.TrashService.RemoveItem(ctx, kind, objectId, info.ShortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, nil)
+}
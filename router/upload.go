@@ -0,0 +1,325 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"privaTutle/internal/claim"
+	"privaTutle/internal/mediastore"
+	"privaTutle/internal/upload"
+	"privaTutle/model"
+	"privaTutle/pkg/auth"
+	"strconv"
+	"strings"
+	"time"
+
+	fileHelper "privaTutle/pkg/file_helper"
+	httpHelper "privaTutle/pkg/http_helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+)
+
+// The resumable upload follows the core protocol of tus 1.0.0 with the
+// creation and termination extensions, so tus clients can be used as they are.
+const (
+	tusVersion        = "1.0.0"
+	tusResumable      = "Tus-Resumable"
+	uploadLength      = "Upload-Length"
+	uploadOffset      = "Upload-Offset"
+	uploadMetadata    = "Upload-Metadata"
+	uploadShortHeader = "Upload-Short-Url"
+	chunkContentType  = "application/offset+octet-stream"
+)
+
+// uploadCompleteTimeout bounds joining the parts of a finished upload, which
+// copies the whole video once.
+const uploadCompleteTimeout = 30 * time.Minute
+
+// parseUploadMetadata reads the comma separated "key base64value" pairs of
+// the Upload-Metadata header.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, model.ErrParameter
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// @Summary CreateUpload
+// @Description Starts a resumable video upload (tus 1.0.0). Upload-Metadata carries expirationTime and the optional password. The upload is addressed by the returned Location.
+// @Tags Media
+// @Param  Authorization  header  string  false  "Authorization"
+// @Param  Tus-Resumable  header  string  true  "1.0.0"
+// @Param  Upload-Length  header  int  true  "size of the video in bytes"
+// @Param  Upload-Metadata  header  string  true  "expirationTime and password, base64 encoded"
+// @Success 201
+// @Header 201 {string} Location "address of the upload"
+// @Router /api/media/upload [post]
+func CreateUpload(g *gin.Context) {
+	g.Header(tusResumable, tusVersion)
+	token := g.Request.Header.Get("Authorization")
+	var objectId string
+	var err error
+	if token != "" {
+		objectId, err = auth.AuthJWT(token)
+		if err != nil {
+			if err == auth.ErrVaild {
+				httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+				return
+			}
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+	}
+
+	length, err := strconv.ParseInt(g.GetHeader(uploadLength), 10, 64)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	metadata, err := parseUploadMetadata(g.GetHeader(uploadMetadata))
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+	expirationTime, err := strconv.ParseInt(metadata["expirationTime"], 10, 64)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	info := &UploadMediaInfo{
+		ExpirationTime: expirationTime,
+		Password:       metadata["password"],
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	password, err := mediastore.HashPassword(info.Password)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := upload.UploadService.CreateUpload(ctx, &upload.Upload{
+		UserId:         objectId,
+		Type:           "video",
+		Password:       password,
+		ExpirationTime: info.ExpirationTime,
+		Length:         length,
+	})
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	g.Header("Location", apiHost+"/api/media/upload/"+data.Id)
+	g.Status(http.StatusCreated)
+}
+
+// @Summary UploadOffset
+// @Description Returns how much of the upload has arrived, to resume it from Upload-Offset.
+// @Tags Media
+// @Param  Authorization  header  string  false  "Authorization"
+// @Param  Tus-Resumable  header  string  true  "1.0.0"
+// @Param  id  path  string  true  "id"
+// @Success 200
+// @Header 200 {string} Upload-Offset "bytes received"
+// @Header 200 {string} Upload-Length "size of the video in bytes"
+// @Router /api/media/upload/{id} [head]
+func UploadOffset(g *gin.Context) {
+	g.Header(tusResumable, tusVersion)
+	g.Header("Cache-Control", "no-store")
+	token := g.Request.Header.Get("Authorization")
+	var objectId string
+	var err error
+	if token != "" {
+		objectId, err = auth.AuthJWT(token)
+		if err != nil {
+			if err == auth.ErrVaild {
+				httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+				return
+			}
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := upload.UploadService.GetUpload(ctx, objectId, g.Param("id"))
+	if err != nil {
+		g.Status(mediaStatus(err))
+		return
+	}
+
+	g.Header(uploadOffset, strconv.FormatInt(data.Offset, 10))
+	g.Header(uploadLength, strconv.FormatInt(data.Length, 10))
+	if data.ShortUrl != "" {
+		g.Header(uploadShortHeader, data.ShortUrl)
+	}
+	g.Status(http.StatusOK)
+}
+
+// @Summary UploadChunk
+// @Description Appends the body at Upload-Offset. The request that completes the upload returns the short code of the media in Upload-Short-Url.
+// @Tags Media
+// @Accept  application/offset+octet-stream
+// @Param  Authorization  header  string  false  "Authorization"
+// @Param  Tus-Resumable  header  string  true  "1.0.0"
+// @Param  Upload-Offset  header  int  true  "offset of the chunk"
+// @Param  id  path  string  true  "id"
+// @Success 204
+// @Header 204 {string} Upload-Offset "bytes received"
+// @Header 204 {string} Upload-Short-Url "short code, once the upload is complete"
+// @Header 204 {string} X-Claim-Token "claim token, only for uploads without Authorization"
+// @Router /api/media/upload/{id} [patch]
+func UploadChunk(g *gin.Context) {
+	g.Header(tusResumable, tusVersion)
+	token := g.Request.Header.Get("Authorization")
+	var objectId string
+	var err error
+	if token != "" {
+		objectId, err = auth.AuthJWT(token)
+		if err != nil {
+			if err == auth.ErrVaild {
+				httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+				return
+			}
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+	}
+
+	if g.ContentType() != chunkContentType {
+		httpHelper.SendError(g, http.StatusUnsupportedMediaType, model.ErrParameter.Error())
+		return
+	}
+	offset, err := strconv.ParseInt(g.GetHeader(uploadOffset), 10, 64)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	data, err := upload.UploadService.GetUpload(ctx, objectId, g.Param("id"))
+	cancel()
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	body := bufio.NewReaderSize(g.Request.Body, 512)
+	contentType := data.ContentType
+	if offset == 0 {
+		head, _ := body.Peek(512)
+		contentType = http.DetectContentType(head)
+		if len(head) > 0 && !fileHelper.IsVideo(contentType) {
+			httpHelper.SendError(g, http.StatusBadRequest, "ErrInvalidFileType")
+			return
+		}
+	}
+
+	// a finished upload whose media could not be created is completed by
+	// sending the last offset again
+	if !data.Done() || offset != data.Offset {
+		// a chunk takes as long as the client needs to send it
+		data, err = upload.UploadService.WriteChunk(g.Request.Context(), data, offset, contentType, body)
+		if err != nil {
+			httpHelper.SendError(g, mediaStatus(err), err.Error())
+			return
+		}
+	}
+	g.Header(uploadOffset, strconv.FormatInt(data.Offset, 10))
+
+	if data.Done() {
+		ctx, cancel := context.WithTimeout(context.Background(), uploadCompleteTimeout)
+		defer cancel()
+
+		media, err := upload.UploadService.Complete(ctx, data)
+		if err != nil {
+			httpHelper.SendError(g, mediaStatus(err), err.Error())
+			return
+		}
+		g.Header(uploadShortHeader, media.ShortUrl)
+
+		if media.UserId == "" {
+			token, err := claim.ClaimService.NewClaim(ctx, claim.KindMedia, media.ShortUrl)
+			if err != nil {
+				httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+				return
+			}
+			g.Header(claimTokenHeader, token)
+		}
+	}
+
+	g.Status(http.StatusNoContent)
+}
+
+// @Summary DeleteUpload
+// @Description Abandons an unfinished upload.
+// @Tags Media
+// @Param  Authorization  header  string  false  "Authorization"
+// @Param  Tus-Resumable  header  string  true  "1.0.0"
+// @Param  id  path  string  true  "id"
+// @Success 204
+// @Router /api/media/upload/{id} [delete]
+func DeleteUpload(g *gin.Context) {
+	g.Header(tusResumable, tusVersion)
+	token := g.Request.Header.Get("Authorization")
+	var objectId string
+	var err error
+	if token != "" {
+		objectId, err = auth.AuthJWT(token)
+		if err != nil {
+			if err == auth.ErrVaild {
+				httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+				return
+			}
+			httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, err := upload.UploadService.GetUpload(ctx, objectId, g.Param("id"))
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+	if data.ShortUrl != "" {
+		// the upload is complete, its media expires like any other
+		httpHelper.SendError(g, http.StatusConflict, model.ErrUploadOffset.Error())
+		return
+	}
+
+	err = upload.UploadService.DeleteUpload(ctx, data)
+	if err != nil {
+		httpHelper.SendError(g, mediaStatus(err), err.Error())
+		return
+	}
+
+	g.Status(http.StatusNoContent)
+}
@@ -3,9 +3,15 @@ package router
 import (
 	"context"
 	"net/http"
+	"privaTutle/internal/analytics"
+	"privaTutle/internal/canonical"
+	"privaTutle/internal/claim"
+	"privaTutle/internal/link"
+	"privaTutle/internal/mediastore"
+	"privaTutle/internal/policy"
+	"privaTutle/internal/trash"
 	"privaTutle/model"
 	"privaTutle/pkg/auth"
-	"privaTutle/service/media"
 	"privaTutle/service/short"
 	"privaTutle/service/user"
 	"strconv"
@@ -21,13 +27,32 @@ func NewUserRouter(group *gin.RouterGroup) {
 	group.POST("/register", Register)
 	group.POST("/login", Login)
 
+	group.GET("/short", ShortCursorList)
 	group.GET("/short/:page/:limit", ShortList)
 	group.DELETE("/short/:shortId", DeleteShort)
 	group.PUT("/short/:shortId", UpdateShort)
-
+	// gin needs the wildcard to share its name with /short/:page/:limit
+	group.GET("/short/:page/stats", ShortStats)
+	group.GET("/short/:page/history", ShortHistory)
+	group.POST("/short/:shortId/rollback", RollbackShort)
+	group.GET("/short/export", ExportShort)
+	group.POST("/short/import", ImportShort)
+	group.POST("/short/:shortId/restore", RestoreShort)
+
+	group.GET("/media", MediaCursorList)
 	group.GET("/media/:page/:limit", MediaList)
 	group.DELETE("/media/:shortId", DeleteMedia)
 	group.PUT("/media/:shortId", UpdateMedia)
+	group.POST("/media/:shortId/restore", RestoreMedia)
+
+	group.GET("/trash", TrashList)
+
+	group.POST("/claim", Claim)
+
+	group.POST("/domain", AddDomain)
+	group.GET("/domain", DomainList)
+	group.POST("/domain/:host/verify", VerifyDomain)
+	group.DELETE("/domain/:host", DeleteDomain)
 }
 
 type RegisterInfo struct {
@@ -125,9 +150,56 @@ func Login(g *gin.Context) {
 	})
 }
 
+type ShortFilterInfo struct {
+	Tag    string    `validate:"max=20"`
+	Folder string    `validate:"max=30"`
+	Query  string    `validate:"max=100"`
+	Status string    `validate:"omitempty,oneof=active scheduled expired exhausted delete"`
+	Health string    `validate:"omitempty,oneof=ok broken unknown unchecked"`
+	From   time.Time `validate:"omitempty"`
+	To     time.Time `validate:"omitempty,gtfield=From"`
+	Sort   string    `validate:"omitempty,oneof=createTime -createTime name -name"`
+}
+
+func bindShortFilter(g *gin.Context) (ShortFilterInfo, error) {
+	from, err := timeQuery(g, "from")
+	if err != nil {
+		return ShortFilterInfo{}, model.ErrParameter
+	}
+	to, err := timeQuery(g, "to")
+	if err != nil {
+		return ShortFilterInfo{}, model.ErrParameter
+	}
+
+	return ShortFilterInfo{
+		Tag:    g.Query("tag"),
+		Folder: g.Query("folder"),
+		Query:  g.Query("q"),
+		Status: g.Query("status"),
+		Health: g.Query("health"),
+		From:   from,
+		To:     to,
+		Sort:   g.Query("sort"),
+	}, nil
+}
+
+func (info ShortFilterInfo) listFilter() *link.ListFilter {
+	return &link.ListFilter{
+		Tag:    info.Tag,
+		Folder: info.Folder,
+		Query:  info.Query,
+		Status: info.Status,
+		Health: info.Health,
+		From:   info.From,
+		To:     info.To,
+		Sort:   info.Sort,
+	}
+}
+
 type ShortListInfo struct {
 	Page  int64 `validate:"required,gte=1"`
 	Limit int64 `validate:"required,gte=1,lte=20"`
+	ShortFilterInfo
 }
 
 // @Summary ShortList
@@ -137,6 +209,14 @@ type ShortListInfo struct {
 // @Param  Authorization  header  string  true  "Authorization"
 // @Param  page  path  int64  true  "page"
 // @Param  limit  path  int64  true  "limit"
+// @Param  tag  query  string  false  "tag"
+// @Param  folder  query  string  false  "folder"
+// @Param  q  query  string  false  "substring of name, code or destination"
+// @Param  status  query  string  false  "active, scheduled, expired, exhausted or delete"
+// @Param  health  query  string  false  "ok, broken, unknown or unchecked"
+// @Param  from  query  string  false  "created at or after, RFC3339"
+// @Param  to  query  string  false  "created before, RFC3339"
+// @Param  sort  query  string  false  "createTime, -createTime, name or -name, default -createTime"
 // @Success 200
 // @Router /api/user/short/{page}/{limit} [get]
 func ShortList(g *gin.Context) {
@@ -161,9 +241,15 @@ func ShortList(g *gin.Context) {
 		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
 		return
 	}
+	filter, err := bindShortFilter(g)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
 	info := ShortListInfo{
-		Page:  page,
-		Limit: limit,
+		Page:            page,
+		Limit:           limit,
+		ShortFilterInfo: filter,
 	}
 
 	validate := validator.New()
@@ -176,7 +262,13 @@ func ShortList(g *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	data, total, err := short.ShortService.ListUserShorts(ctx, objectId, info.Page, info.Limit)
+	err = importLegacyShorts(ctx, objectId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, total, err := link.LinkService.ListUserLinks(ctx, objectId, info.listFilter(), info.Page, info.Limit)
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
 		return
@@ -185,11 +277,224 @@ func ShortList(g *gin.Context) {
 	httpHelper.SendResponse(g, gin.H{"data": data, "total": total})
 }
 
+type ShortCursorListInfo struct {
+	Cursor string
+	Limit  int64 `validate:"gte=1,lte=100"`
+	ShortFilterInfo
+}
+
+// @Summary ShortCursorList
+// @Description Pages with the opaque next token of the previous response instead of page numbers, next is empty on the last page. The filters must stay the same between pages.
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  cursor  query  string  false  "next of the previous page"
+// @Param  limit  query  int64  false  "1 to 100, default 20"
+// @Param  tag  query  string  false  "tag"
+// @Param  folder  query  string  false  "folder"
+// @Param  q  query  string  false  "substring of name, code or destination"
+// @Param  status  query  string  false  "active, scheduled, expired, exhausted or delete"
+// @Param  health  query  string  false  "ok, broken, unknown or unchecked"
+// @Param  from  query  string  false  "created at or after, RFC3339"
+// @Param  to  query  string  false  "created before, RFC3339"
+// @Param  sort  query  string  false  "createTime, -createTime, name or -name, default -createTime"
+// @Success 200
+// @Router /api/user/short [get]
+func ShortCursorList(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	limit, err := strconv.ParseInt(g.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	filter, err := bindShortFilter(g)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+	info := ShortCursorListInfo{
+		Cursor:          g.Query("cursor"),
+		Limit:           limit,
+		ShortFilterInfo: filter,
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = importLegacyShorts(ctx, objectId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, next, err := link.LinkService.ListUserLinksAfter(ctx, objectId, info.listFilter(), info.Cursor, info.Limit)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{"data": data, "next": next})
+}
+
+// importLegacyShorts copies the user's shorts from before the link collection
+// into it, once, so ShortList keeps showing them with their name, creation
+// time and status. Hash codes were shared by everyone shortening the same
+// destination, so every owner gets a read-only copy of the link.
+func importLegacyShorts(ctx context.Context, objectId string) error {
+	setting, err := link.LinkService.GetLinkSetting(ctx, objectId)
+	if err != nil {
+		return err
+	}
+	if setting.Imported {
+		return nil
+	}
+
+	const limit = 100
+	for page := int64(1); ; page++ {
+		data, total, err := short.ShortService.ListUserShorts(ctx, objectId, page, limit)
+		if err != nil {
+			return err
+		}
+
+		links := make([]*link.Link, 0, len(data))
+		for _, d := range data {
+			status := link.StatusActive
+			if d.Status == link.StatusDelete {
+				status = link.StatusDelete
+			}
+			links = append(links, &link.Link{
+				ShortUrl:   d.ShortUrl,
+				UserId:     objectId,
+				LeadUrl:    d.LeadUrl,
+				Name:       d.Name,
+				Status:     status,
+				CreateTime: d.CreateTime,
+			})
+		}
+		err = link.LinkService.ImportLinks(ctx, links)
+		if err != nil {
+			return err
+		}
+
+		if len(data) == 0 || page*limit >= total {
+			break
+		}
+	}
+
+	return link.LinkService.MarkLinksImported(ctx, objectId)
+}
+
+// timeQuery parses an optional RFC3339 query parameter.
+func timeQuery(g *gin.Context, key string) (time.Time, error) {
+	value := g.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+type ShortStatsInfo struct {
+	ShortId  string    `validate:"required"`
+	From     time.Time `validate:"required"`
+	To       time.Time `validate:"required,gtfield=From"`
+	Interval string    `validate:"required,oneof=hour day"`
+}
+
+// @Summary ShortStats
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  shortId  path  string  true  "shortId"
+// @Param  from  query  string  false  "RFC3339, default 30 days ago"
+// @Param  to  query  string  false  "RFC3339, default now"
+// @Param  interval  query  string  false  "hour or day, default day"
+// @Success 200
+// @Router /api/user/short/{shortId}/stats [get]
+func ShortStats(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	from, err := timeQuery(g, "from")
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	to, err := timeQuery(g, "to")
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+	info := ShortStatsInfo{
+		ShortId:  g.Param("page"),
+		From:     from,
+		To:       to,
+		Interval: g.DefaultQuery("interval", analytics.IntervalDay),
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = link.LinkService.GetUserLink(ctx, objectId, info.ShortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := analytics.AnalyticsService.ShortStats(ctx, info.ShortId, info.From, info.To, info.Interval)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, data)
+}
+
 type DeleteShortInfo struct {
 	ShortId string `validate:"required"`
 }
 
 // @Summary DeleteShort
+// @Description Moves the short link to the trash, it can be restored until purgeTime.
 // @Tags User
 // @Accept  json
 // @produce json
@@ -223,13 +528,31 @@ func DeleteShort(g *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err = short.ShortService.UpdateShortStatus(ctx, objectId, info.ShortId, "delete")
+	owner, err := claim.ClaimService.ServiceOwner(ctx, objectId, claim.KindShort, info.ShortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	_, err = short.ShortService.UpdateShortStatus(ctx, owner, info.ShortId, "delete")
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	httpHelper.SendResponse(g, nil)
+	_, err = link.LinkService.UpdateLinkStatus(ctx, objectId, info.ShortId, link.StatusDelete)
+	if err != nil && err != model.ErrShortNotFound {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	item, err := trash.TrashService.AddItem(ctx, trash.KindShort, objectId, info.ShortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{"purgeTime": item.PurgeTime})
 }
 
 type MediaListInfo struct {
@@ -283,19 +606,77 @@ func MediaList(g *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	data, total, err := media.MediaService.ListUserMedia(ctx, objectId, page, limit)
+	data, total, err := mediastore.MediaStoreService.ListUserMedia(ctx, objectId, page, limit)
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	httpHelper.SendResponse(g, gin.H{"data": data, "total": total})
 }
 
+type MediaCursorListInfo struct {
+	Cursor string
+	Limit  int64 `validate:"gte=1,lte=100"`
+}
+
+// @Summary MediaCursorList
+// @Description Pages with the opaque next token of the previous response instead of page numbers, next is empty on the last page.
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  cursor  query  string  false  "next of the previous page"
+// @Param  limit  query  int64  false  "1 to 100, default 20"
+// @Success 200
+// @Router /api/user/media [get]
+func MediaCursorList(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	limit, err := strconv.ParseInt(g.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+	info := MediaCursorListInfo{
+		Cursor: g.Query("cursor"),
+		Limit:  limit,
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data, next, err := mediastore.MediaStoreService.ListUserMediaAfter(ctx, objectId, info.Cursor, info.Limit)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{"data": data, "next": next})
+}
+
 type DeleteMediaInfo struct {
 	ShortId string `validate:"required"`
 }
 
 // @Summary DeleteMedia
+// @Description Moves the media to the trash, it can be restored until purgeTime.
 // @Tags User
 // @Accept  json
 // @produce json
@@ -329,17 +710,31 @@ func DeleteMedia(g *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err = media.MediaService.UpdateMediaStatus(ctx, objectId, info.ShortId, "delete")
+	_, err = mediastore.MediaStoreService.UpdateMediaStatus(ctx, objectId, info.ShortId, mediastore.StatusDelete)
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	httpHelper.SendResponse(g, nil)
+	item, err := trash.TrashService.AddItem(ctx, trash.KindMedia, objectId, info.ShortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{"purgeTime": item.PurgeTime})
 }
 
 type UpdateShortInfo struct {
-	Name string `validate:"max=15"`
+	Name        string    `validate:"max=15"`
+	Tags        []string  `validate:"max=10,dive,min=1,max=20"`
+	Folder      string    `validate:"max=30"`
+	LeadUrl     string    `validate:"omitempty,url"`
+	ActivatesAt time.Time `validate:"omitempty"`
+	ExpiresAt   time.Time `validate:"omitempty,gt,gtfield=ActivatesAt"`
+	Password    string    `validate:"max=20"`
+	// Rules replace the destination rules, an empty list removes them.
+	Rules []RuleInfo `validate:"omitempty,max=10,dive"`
 }
 
 // @Summary UpdateShort
@@ -381,8 +776,103 @@ func UpdateShort(g *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	owner, err := claim.ClaimService.ServiceOwner(ctx, objectId, claim.KindShort, shortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// a legacy short needs its copy before the name can be kept there
+	err = importLegacyShorts(ctx, objectId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// everything is checked before the first change is written, so a rejected
+	// edit leaves the link as it was
+	current, err := link.LinkService.GetUserLink(ctx, objectId, shortId)
+	if err != nil {
+		httpHelper.SendError(g, createShortStatus(err), err.Error())
+		return
+	}
+	changesLink := info.LeadUrl != "" || !info.ActivatesAt.IsZero() || !info.ExpiresAt.IsZero() || info.Password != "" || info.Rules != nil
+	if changesLink && current.Shared != "" {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrShortShared.Error())
+		return
+	}
+
+	var leadUrl string
+	if info.LeadUrl != "" {
+		leadUrl, err = canonical.URL(info.LeadUrl, stripTracking)
+		if err == nil {
+			err = policy.PolicyService.Check(ctx, leadUrl)
+		}
+		if err != nil {
+			httpHelper.SendError(g, createShortStatus(err), err.Error())
+			return
+		}
+	}
+
+	var rules []link.Rule
+	if info.Rules != nil {
+		rules, err = linkRules(ctx, info.Rules)
+		if err != nil {
+			httpHelper.SendError(g, createShortStatus(err), err.Error())
+			return
+		}
+	}
+
 	if info.Name != "" {
-		_, err = short.ShortService.UpdateShortName(ctx, objectId, shortId, info.Name)
+		_, err = short.ShortService.UpdateShortName(ctx, owner, shortId, info.Name)
+		if err == nil {
+			_, err = link.LinkService.UpdateLinkName(ctx, objectId, shortId, info.Name)
+		}
+	}
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+
+	}
+
+	if info.Tags != nil || info.Folder != "" {
+		_, err = link.LinkService.UpdateLinkLabels(ctx, objectId, shortId, info.Tags, info.Folder)
+	}
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+
+	}
+
+	if leadUrl != "" {
+		_, err = link.LinkService.UpdateLinkLeadUrl(ctx, objectId, shortId, leadUrl)
+	}
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+
+	}
+
+	if !info.ActivatesAt.IsZero() || !info.ExpiresAt.IsZero() {
+		_, err = link.LinkService.UpdateLinkSchedule(ctx, objectId, shortId, info.ActivatesAt, info.ExpiresAt)
+	}
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+
+	}
+
+	if info.Password != "" {
+		_, err = link.LinkService.UpdateLinkPassword(ctx, objectId, shortId, info.Password)
+	}
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+
+	}
+
+	if info.Rules != nil {
+		_, err = link.LinkService.UpdateLinkRules(ctx, objectId, shortId, rules)
 	}
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
@@ -393,6 +883,125 @@ func UpdateShort(g *gin.Context) {
 	httpHelper.SendResponse(g, nil)
 }
 
+// @Summary ShortHistory
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  shortId  path  string  true  "shortId"
+// @Success 200
+// @Router /api/user/short/{shortId}/history [get]
+func ShortHistory(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	// gin names this wildcard after /short/:page/:limit
+	shortId := g.Param("page")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	current, err := link.LinkService.GetUserLink(ctx, objectId, shortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := link.LinkService.ListLinkHistory(ctx, current.Id)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{
+		"version": current.Version,
+		"leadUrl": current.LeadUrl,
+		"data":    data,
+	})
+}
+
+type RollbackShortInfo struct {
+	Version int64 `validate:"required,gte=1"`
+}
+
+// @Summary RollbackShort
+// @Tags User
+// @Accept  json
+// @produce json
+// @Param  Authorization  header  string  true  "Authorization"
+// @Param  body  body  RollbackShortInfo  true  "body"
+// @Param  shortId  path  string  true  "shortId"
+// @Success 200
+// @Router /api/user/short/{shortId}/rollback [post]
+func RollbackShort(g *gin.Context) {
+	token := g.Request.Header.Get("Authorization")
+	objectId, err := auth.AuthJWT(token)
+	if err != nil {
+		if err == auth.ErrVaild {
+			httpHelper.SendError(g, http.StatusUnauthorized, err.Error())
+			return
+		}
+		httpHelper.SendError(g, http.StatusInternalServerError, model.ErrInternal.Error())
+		return
+	}
+
+	info := RollbackShortInfo{}
+	g.BindJSON(&info)
+	shortId := g.Param("shortId")
+	if shortId == "" {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	validate := validator.New()
+	err = validate.Struct(info)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, model.ErrParameter.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	current, err := link.LinkService.GetUserLink(ctx, objectId, shortId)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history, err := link.LinkService.GetLinkHistory(ctx, current.Id, info.Version)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the policy may have changed since this destination was in use
+	err = policy.PolicyService.Check(ctx, history.LeadUrl)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := link.LinkService.UpdateLinkLeadUrl(ctx, objectId, shortId, history.LeadUrl)
+	if err != nil {
+		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpHelper.SendResponse(g, gin.H{
+		"version": data.Version,
+		"leadUrl": data.LeadUrl,
+	})
+}
+
 type UpdateMediaInfo struct {
 	Name           string `validate:"max=15"`
 	ExpirationTime int64  `validate:"required,gte=1,lte=86400"`
@@ -439,7 +1048,7 @@ func UpdateMedia(g *gin.Context) {
 	defer cancel()
 
 	if info.Name != "" {
-		_, err = media.MediaService.UpdateMediaName(ctx, objectId, shortId, info.Name)
+		_, err = mediastore.MediaStoreService.UpdateMediaName(ctx, objectId, shortId, info.Name)
 	}
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
@@ -448,7 +1057,7 @@ func UpdateMedia(g *gin.Context) {
 	}
 
 	if info.ExpirationTime != 0 {
-		_, err = media.MediaService.UpdateMediaExpirationTime(ctx, objectId, shortId, info.ExpirationTime)
+		_, err = mediastore.MediaStoreService.UpdateMediaExpirationTime(ctx, objectId, shortId, info.ExpirationTime)
 	}
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())
@@ -457,7 +1066,7 @@ func UpdateMedia(g *gin.Context) {
 	}
 
 	if info.Password != "" {
-		_, err = media.MediaService.UpdateMediaPassword(ctx, objectId, shortId, info.Password)
+		_, err = mediastore.MediaStoreService.UpdateMediaPassword(ctx, objectId, shortId, info.Password)
 	}
 	if err != nil {
 		httpHelper.SendError(g, http.StatusBadRequest, err.Error())